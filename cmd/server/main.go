@@ -7,6 +7,7 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"strings"
 	"syscall"
 	"time"
 
@@ -18,9 +19,12 @@ import (
 	"github.com/thcyron/cashflow/internal/api"
 	"github.com/thcyron/cashflow/internal/cf"
 	"github.com/thcyron/cashflow/internal/price/cache"
+	"github.com/thcyron/cashflow/internal/price/mux"
 	"github.com/thcyron/cashflow/internal/price/yahoo"
 	"github.com/thcyron/cashflow/internal/repository/fs"
 	"github.com/thcyron/cashflow/internal/repository/git"
+	"github.com/thcyron/cashflow/internal/repository/option"
+	"github.com/thcyron/cashflow/internal/repository/savingsplan"
 )
 
 func main() {
@@ -34,6 +38,10 @@ func main() {
 		gitUser = flagSet.String("git.user", "git", "Git SSH username")
 		gitKey  = flagSet.String("git.key", "", "Git SSH private key")
 
+		baseCurrency = flagSet.String("base-currency", "EUR", "Currency all amounts are converted into")
+		riskFreeRate = flagSet.Float64("risk-free-rate", 0, "Annual risk-free rate for Sharpe and Sortino ratios, like 0.01 for 1%")
+		benchmarkIDs = flagSet.String("benchmarks", "", "Comma-separated list of benchmark symbols or ISINs, optionally followed by :currency")
+
 		_ = flagSet.String("config", "", "config file (optional)")
 	)
 
@@ -46,6 +54,19 @@ func main() {
 		os.Exit(1)
 	}
 
+	var benchmarks []*cf.Stock
+	for _, id := range strings.Split(*benchmarkIDs, ",") {
+		if id = strings.TrimSpace(id); id == "" {
+			continue
+		}
+		benchmark, err := cf.ParseBenchmark(id)
+		if err != nil {
+			logger.Log("msg", "error parsing benchmark", "err", err)
+			os.Exit(1)
+		}
+		benchmarks = append(benchmarks, benchmark)
+	}
+
 	var repo cf.Repository
 	if *fsDir != "" {
 		repo = fs.NewRepository(*fsDir)
@@ -67,14 +88,22 @@ func main() {
 	var (
 		yahooClient        = yahoo.NewClient()
 		yahooPriceProvider = yahoo.NewProvider(yahooClient)
-		priceCache         = cache.New(yahooPriceProvider)
-		api                = api.New(log.With(logger, "component", "api"), repo, priceCache.Price)
+		yahooRateProvider  = yahoo.NewRateProvider(yahooClient)
+		priceProvider      = mux.NewProvider(yahooPriceProvider)
+		priceCache         = cache.New(priceProvider)
+		rateCache          = cache.NewRates(yahooRateProvider)
+		converter          = cf.NewConverter(*baseCurrency, rateCache.Rate)
+		plans              = option.NewRepository(savingsplan.NewRepository(log.With(logger, "component", "savingsplan"), repo, priceCache.Price, converter))
+		api                = api.New(log.With(logger, "component", "api"), plans, priceCache.Price, converter, benchmarks, *riskFreeRate)
 		runGroup           run.Group
 	)
 
+	// Crypto currencies are priced by their pair symbols like BTC-EUR.
+	priceProvider.Handle(cf.CryptoKind, mux.NewPairProvider(yahoo.NewPairProvider(yahooClient)))
+
 	runGroup.Add(run.SignalHandler(context.Background(), syscall.SIGTERM, syscall.SIGINT))
 	runGroup.Add(apiServer(api))
-	runGroup.Add(priceUpdater(logger, repo, priceCache))
+	runGroup.Add(priceUpdater(logger, repo, benchmarks, priceCache, rateCache, *baseCurrency))
 
 	if err := runGroup.Run(); err != nil {
 		if errors.As(err, &run.SignalError{}) {
@@ -102,12 +131,12 @@ func apiServer(server *api.Server) (execute func() error, interrupt func(error))
 		}
 }
 
-func priceUpdater(logger log.Logger, repo cf.Repository, cache *cache.Cache) (execute func() error, interrupt func(error)) {
+func priceUpdater(logger log.Logger, repo cf.Repository, benchmarks []*cf.Stock, cache *cache.Cache, rates *cache.Rates, baseCurrency string) (execute func() error, interrupt func(error)) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	update := func() {
 		logger.Log("msg", "updating prices")
-		if err := updatePrices(ctx, repo, cache); err != nil {
+		if err := updatePrices(ctx, logger, repo, benchmarks, cache, rates, baseCurrency); err != nil {
 			logger.Log(
 				"msg", "error updating prices",
 				"err", err,
@@ -132,12 +161,93 @@ func priceUpdater(logger log.Logger, repo cf.Repository, cache *cache.Cache) (ex
 		}
 }
 
-func updatePrices(ctx context.Context, repo cf.Repository, cache *cache.Cache) error {
+func updatePrices(ctx context.Context, logger log.Logger, repo cf.Repository, benchmarks []*cf.Stock, cache *cache.Cache, rates *cache.Rates, baseCurrency string) error {
 	stocks, err := repo.Stocks(ctx)
 	if err != nil {
 		return err
 	}
-	return cache.UpdateHistory(ctx, stocks)
+	depots, err := repo.Depots(ctx)
+	if err != nil {
+		return err
+	}
+	// Expired derivatives and matured bonds no longer get new prices, so
+	// their history is only fetched once.
+	var traded []*cf.Stock
+	for _, stock := range stocks {
+		if !stock.Expired(time.Now()) || !cache.Cached(stock) {
+			traded = append(traded, stock)
+		}
+	}
+	if err := cache.UpdateHistory(ctx, traded, time.Time{}); err != nil {
+		logger.Log("msg", "error updating stock prices", "err", err)
+	}
+
+	// Benchmarks are updated separately, so that a benchmark without
+	// prices does not affect the portfolio. Their prices are needed from the
+	// first transaction of the portfolio on, which is replayed into them.
+	var since time.Time
+	for _, stock := range stocks {
+		if first := stock.Since(); !first.IsZero() && (since.IsZero() || first.Before(since)) {
+			since = first
+		}
+	}
+	var resolved []*cf.Stock
+	for _, benchmark := range benchmarks {
+		benchmark, err := cf.ResolveBenchmark(benchmark, stocks)
+		if err != nil {
+			logger.Log("msg", "skipping benchmark", "err", err)
+			continue
+		}
+		resolved = append(resolved, benchmark)
+	}
+	if err := cache.UpdateHistory(ctx, resolved, since); err != nil {
+		logger.Log("msg", "error updating benchmark prices", "err", err)
+	}
+
+	// Exchange rates are needed from the first use of each currency on.
+	currencies := cf.Currencies(append(append([]*cf.Stock{}, stocks...), resolved...), depots)
+	first := firstUses(stocks, depots)
+	for _, benchmark := range resolved {
+		if date, ok := first[benchmark.Currency]; benchmark.Currency != "" && !since.IsZero() && (!ok || since.Before(date)) {
+			first[benchmark.Currency] = since
+		}
+	}
+	if err := rates.UpdateHistory(ctx, currencies, baseCurrency, first); err != nil {
+		return err
+	}
+	return nil
+}
+
+// firstUses returns the date of the first transaction or savings plan
+// execution in each currency. The currency of a stock is used by all of its
+// transactions, since their values are based on its prices.
+func firstUses(stocks []*cf.Stock, depots []*cf.Depot) map[string]time.Time {
+	first := map[string]time.Time{}
+	use := func(date time.Time, currency string) {
+		if earliest, ok := first[currency]; currency != "" && (!ok || date.Before(earliest)) {
+			first[currency] = date
+		}
+	}
+	for _, s := range stocks {
+		for _, t := range s.Transactions {
+			use(t.Date, s.Currency)
+			use(t.Date, t.Currency)
+		}
+		for _, p := range s.SavingsPlans {
+			use(p.Start, s.Currency)
+			use(p.Start, p.Currency)
+		}
+	}
+	for _, d := range depots {
+		for _, t := range d.Transactions {
+			currency := d.Currency
+			if t.Currency != "" {
+				currency = t.Currency
+			}
+			use(t.Date, currency)
+		}
+	}
+	return first
 }
 
 func makeLogger() log.Logger {
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/thcyron/cashflow/internal/cf"
+)
+
+type AllocationEntry struct {
+	Key    string  `json:"key"`
+	Value  string  `json:"value"`
+	Weight float64 `json:"weight"`
+}
+
+type Allocation struct {
+	Currency string            `json:"currency"`
+	AsOf     string            `json:"as_of"`
+	By       string            `json:"by"`
+	Total    string            `json:"total"`
+	Entries  []AllocationEntry `json:"entries"`
+}
+
+type allocationResponse struct {
+	Allocation Allocation `json:"allocation"`
+}
+
+func (s *Server) allocationHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, ps httprouter.Params) error {
+	asOf, err := parseAsOf(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	by := cf.AssetClass
+	if b := r.URL.Query().Get("by"); b != "" {
+		if by, err = cf.ParseDimension(b); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil
+		}
+	}
+
+	stocks, depots, err := s.selection(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	transactions, stats, err := cf.CalculateStats(stocks, depots)
+	if err != nil {
+		return err
+	}
+
+	portfolio := cf.PortfolioAt(transactions, stats, asOf)
+	total, entries := cf.CalculateAllocation(s.priceFunc, portfolio, asOf, by)
+
+	allocation := Allocation{
+		Currency: s.currency(),
+		AsOf:     asOf.Format("2006-01-02"),
+		By:       string(by),
+		Total:    total.String(),
+		Entries:  []AllocationEntry{},
+	}
+	for _, e := range entries {
+		allocation.Entries = append(allocation.Entries, AllocationEntry{
+			Key:    e.Key,
+			Value:  e.Value.String(),
+			Weight: e.Weight,
+		})
+	}
+
+	return json.NewEncoder(w).Encode(allocationResponse{
+		Allocation: allocation,
+	})
+}
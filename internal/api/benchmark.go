@@ -0,0 +1,39 @@
+package api
+
+import (
+	"github.com/thcyron/cashflow/internal/cf"
+)
+
+type Benchmark struct {
+	Stock              Stock             `json:"stock"`
+	Value              string            `json:"value"`
+	Performances       Performances      `json:"performances"`
+	Alpha              *float64          `json:"alpha"`
+	TrackingDifference *float64          `json:"tracking_difference"`
+	Periods            []BenchmarkPeriod `json:"periods"`
+}
+
+type BenchmarkPeriod struct {
+	PeriodPerformance
+	Alpha              *float64 `json:"alpha"`
+	TrackingDifference *float64 `json:"tracking_difference"`
+}
+
+func EncodeBenchmark(comparison cf.BenchmarkComparison) Benchmark {
+	benchmark := Benchmark{
+		Stock:              encodeStock(comparison.Benchmark),
+		Value:              comparison.Value.String(),
+		Performances:       EncodePerformances(comparison.Performances),
+		Alpha:              encodeReturn(comparison.Alpha),
+		TrackingDifference: encodeReturn(comparison.TrackingDifference),
+		Periods:            []BenchmarkPeriod{},
+	}
+	for _, p := range comparison.Periods {
+		benchmark.Periods = append(benchmark.Periods, BenchmarkPeriod{
+			PeriodPerformance:  EncodePeriodPerformances([]cf.PeriodPerformance{p.PeriodPerformance})[0],
+			Alpha:              encodeReturn(p.Alpha),
+			TrackingDifference: encodeReturn(p.TrackingDifference),
+		})
+	}
+	return benchmark
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/thcyron/cashflow/internal/cf"
+)
+
+type DividendGroup struct {
+	Key         string `json:"key"`
+	Stock       *Stock `json:"stock,omitempty"`
+	Amount      string `json:"amount"`
+	GrossAmount string `json:"gross_amount"`
+}
+
+func encodeDividendGroups(groups []cf.DividendGroup) []DividendGroup {
+	encoded := []DividendGroup{}
+	for _, g := range groups {
+		group := DividendGroup{
+			Key:         g.Key,
+			Amount:      g.Amount.String(),
+			GrossAmount: g.GrossAmount.String(),
+		}
+		if g.Stock != nil {
+			stock := encodeStock(g.Stock)
+			group.Stock = &stock
+		}
+		encoded = append(encoded, group)
+	}
+	return encoded
+}
+
+type DividendYield struct {
+	Stock        Stock    `json:"stock"`
+	Shares       string   `json:"shares"`
+	Dividends    string   `json:"dividends"`
+	YieldOnCost  *float64 `json:"yield_on_cost"`
+	CurrentYield *float64 `json:"current_yield"`
+}
+
+type DividendForecast struct {
+	Date   string `json:"date"`
+	Stock  Stock  `json:"stock"`
+	Amount string `json:"amount"`
+}
+
+type Dividends struct {
+	Currency       string             `json:"currency"`
+	Begin          *string            `json:"begin"`
+	End            string             `json:"end"`
+	Amount         string             `json:"amount"`
+	GrossAmount    string             `json:"gross_amount"`
+	ByYear         []DividendGroup    `json:"by_year"`
+	ByMonth        []DividendGroup    `json:"by_month"`
+	ByStock        []DividendGroup    `json:"by_stock"`
+	ByDepot        []DividendGroup    `json:"by_depot"`
+	Yields         []DividendYield    `json:"yields"`
+	Forecast       []DividendForecast `json:"forecast"`
+	ForecastAmount string             `json:"forecast_amount"`
+}
+
+func EncodeDividendReport(report cf.DividendReport) Dividends {
+	dividends := Dividends{
+		Amount:         report.Amount.String(),
+		GrossAmount:    report.GrossAmount.String(),
+		ByYear:         encodeDividendGroups(report.ByYear),
+		ByMonth:        encodeDividendGroups(report.ByMonth),
+		ByStock:        encodeDividendGroups(report.ByStock),
+		ByDepot:        encodeDividendGroups(report.ByDepot),
+		Yields:         []DividendYield{},
+		Forecast:       []DividendForecast{},
+		ForecastAmount: report.ForecastAmount().String(),
+	}
+	for _, y := range report.Yields {
+		dividends.Yields = append(dividends.Yields, DividendYield{
+			Stock:        encodeStock(y.Stock),
+			Shares:       y.Shares.String(),
+			Dividends:    y.Dividends.String(),
+			YieldOnCost:  encodeReturn(y.YieldOnCost),
+			CurrentYield: encodeReturn(y.CurrentYield),
+		})
+	}
+	for _, f := range report.Forecast {
+		dividends.Forecast = append(dividends.Forecast, DividendForecast{
+			Date:   f.Date.Format("2006-01-02"),
+			Stock:  encodeStock(f.Stock),
+			Amount: f.Amount.String(),
+		})
+	}
+	return dividends
+}
+
+type dividendsResponse struct {
+	Dividends Dividends `json:"dividends"`
+}
+
+func (s *Server) dividendsHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, ps httprouter.Params) error {
+	begin, end, err := parseRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	stocks, depots, err := s.selection(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	transactions, stats, err := cf.CalculateStats(stocks, depots)
+	if err != nil {
+		return err
+	}
+
+	dividends := EncodeDividendReport(cf.CalculateDividendReport(s.priceFunc, transactions, stats, begin, end))
+	dividends.Currency = s.currency()
+	if !begin.IsZero() {
+		b := begin.Format("2006-01-02")
+		dividends.Begin = &b
+	}
+	dividends.End = end.Format("2006-01-02")
+
+	return json.NewEncoder(w).Encode(dividendsResponse{
+		Dividends: dividends,
+	})
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/thcyron/cashflow/internal/cf"
+)
+
+type RealizedGain struct {
+	Stock          Stock  `json:"stock"`
+	Depot          string `json:"depot"`
+	Lot            string `json:"lot"`
+	Acquired       string `json:"acquired"`
+	Sold           string `json:"sold"`
+	HoldingDays    int    `json:"holding_days"`
+	Term           string `json:"term"`
+	Shares         string `json:"shares"`
+	Proceeds       string `json:"proceeds"`
+	GrossProceeds  string `json:"gross_proceeds"`
+	CostBasis      string `json:"cost_basis"`
+	GrossCostBasis string `json:"gross_cost_basis"`
+	Fees           string `json:"fees"`
+	Taxes          string `json:"taxes"`
+	Profit         string `json:"profit"`
+}
+
+func EncodeRealizedGain(gain cf.RealizedGain) RealizedGain {
+	term := "short"
+	if gain.LongTerm() {
+		term = "long"
+	}
+	return RealizedGain{
+		Stock:          encodeStock(gain.Stock),
+		Depot:          gain.Depot,
+		Lot:            gain.Lot,
+		Acquired:       gain.Acquired.Format("2006-01-02"),
+		Sold:           gain.Sold.Format("2006-01-02"),
+		HoldingDays:    gain.HoldingDays(),
+		Term:           term,
+		Shares:         gain.Shares.String(),
+		Proceeds:       gain.Proceeds.String(),
+		GrossProceeds:  gain.GrossProceeds.String(),
+		CostBasis:      gain.CostBasis.String(),
+		GrossCostBasis: gain.GrossCostBasis.String(),
+		Fees:           gain.Fees.String(),
+		Taxes:          gain.Taxes.String(),
+		Profit:         gain.Profit.String(),
+	}
+}
+
+type RealizedGainsYear struct {
+	Year      int    `json:"year"`
+	Proceeds  string `json:"proceeds"`
+	CostBasis string `json:"cost_basis"`
+	Fees      string `json:"fees"`
+	Taxes     string `json:"taxes"`
+	Profit    string `json:"profit"`
+	Loss      string `json:"loss"`
+	ShortTerm string `json:"short_term"`
+	LongTerm  string `json:"long_term"`
+}
+
+func EncodeRealizedGainsYear(year cf.RealizedGainsYear) RealizedGainsYear {
+	return RealizedGainsYear{
+		Year:      year.Year,
+		Proceeds:  year.Proceeds.String(),
+		CostBasis: year.CostBasis.String(),
+		Fees:      year.Fees.String(),
+		Taxes:     year.Taxes.String(),
+		Profit:    year.Profit.String(),
+		Loss:      year.Loss.String(),
+		ShortTerm: year.ShortTerm.String(),
+		LongTerm:  year.LongTerm.String(),
+	}
+}
+
+type gainsResponse struct {
+	Currency string              `json:"currency"`
+	Years    []RealizedGainsYear `json:"years"`
+	Gains    []RealizedGain      `json:"gains"`
+}
+
+var gainsCSVHeader = []string{
+	"stock", "isin", "depot", "lot", "acquired", "sold", "holding_days", "term",
+	"shares", "proceeds", "gross_proceeds", "cost_basis", "gross_cost_basis",
+	"fees", "taxes", "profit",
+}
+
+func (s *Server) gainsHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, ps httprouter.Params) error {
+	query := r.URL.Query()
+
+	begin, end, err := parseRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	if year := query.Get("year"); year != "" {
+		y, err := strconv.Atoi(year)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid year %q", year), http.StatusBadRequest)
+			return nil
+		}
+		begin, end = cf.Date(y, 1, 1), cf.Date(y, 12, 31)
+	}
+
+	format := query.Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		http.Error(w, fmt.Sprintf("invalid format %q", format), http.StatusBadRequest)
+		return nil
+	}
+
+	stocks, depots, err := s.selection(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	transactions, stats, err := cf.CalculateStats(stocks, depots)
+	if err != nil {
+		return err
+	}
+
+	gains := cf.CalculateRealizedGains(transactions, stats, begin, end)
+
+	if format == "csv" {
+		return writeGainsCSV(w, gains)
+	}
+
+	response := gainsResponse{
+		Currency: s.currency(),
+		Years:    []RealizedGainsYear{},
+		Gains:    []RealizedGain{},
+	}
+	for _, year := range cf.SummarizeRealizedGains(gains) {
+		response.Years = append(response.Years, EncodeRealizedGainsYear(year))
+	}
+	for _, gain := range gains {
+		response.Gains = append(response.Gains, EncodeRealizedGain(gain))
+	}
+	return json.NewEncoder(w).Encode(response)
+}
+
+func writeGainsCSV(w http.ResponseWriter, gains []cf.RealizedGain) error {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="gains-%s.csv"`, time.Now().Format("2006-01-02")))
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(gainsCSVHeader); err != nil {
+		return err
+	}
+	for _, gain := range gains {
+		g := EncodeRealizedGain(gain)
+		if err := cw.Write([]string{
+			g.Stock.Name, g.Stock.ISIN, g.Depot, g.Lot, g.Acquired, g.Sold,
+			strconv.Itoa(g.HoldingDays), g.Term, g.Shares, g.Proceeds,
+			g.GrossProceeds, g.CostBasis, g.GrossCostBasis, g.Fees, g.Taxes,
+			g.Profit,
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/shopspring/decimal"
+
+	"github.com/thcyron/cashflow/internal/cf"
+)
+
+type HarvestLot struct {
+	Stock            Stock  `json:"stock"`
+	Depot            string `json:"depot"`
+	Lot              string `json:"lot"`
+	Acquired         string `json:"acquired"`
+	Shares           string `json:"shares"`
+	CostBasis        string `json:"cost_basis"`
+	Value            string `json:"value"`
+	Loss             string `json:"loss"`
+	HoldingDays      int    `json:"holding_days"`
+	Term             string `json:"term"`
+	CumulativeShares string `json:"cumulative_shares"`
+	CumulativeResult string `json:"cumulative_result"`
+}
+
+type HarvestSale struct {
+	Stock     Stock   `json:"stock"`
+	Depot     string  `json:"depot"`
+	Lot       *string `json:"lot"`
+	Shares    string  `json:"shares"`
+	Proceeds  string  `json:"proceeds"`
+	CostBasis string  `json:"cost_basis"`
+	Loss      string  `json:"loss"`
+}
+
+type Harvest struct {
+	Currency string        `json:"currency"`
+	AsOf     string        `json:"as_of"`
+	Lots     []HarvestLot  `json:"lots"`
+	Target   string        `json:"target"`
+	Sales    []HarvestSale `json:"sales"`
+	Loss     string        `json:"loss"`
+}
+
+func EncodeHarvest(harvest cf.Harvest) Harvest {
+	encoded := Harvest{
+		Lots:   []HarvestLot{},
+		Target: harvest.Target.String(),
+		Sales:  []HarvestSale{},
+		Loss:   harvest.Loss.String(),
+	}
+	for _, l := range harvest.Lots {
+		term := "short"
+		if l.LongTerm {
+			term = "long"
+		}
+		encoded.Lots = append(encoded.Lots, HarvestLot{
+			Stock:            encodeStock(l.Stock),
+			Depot:            l.Depot,
+			Lot:              l.Lot,
+			Acquired:         l.Acquired.Format("2006-01-02"),
+			Shares:           l.Shares.String(),
+			CostBasis:        l.CostBasis.String(),
+			Value:            l.Value.String(),
+			Loss:             l.Loss.String(),
+			HoldingDays:      l.HoldingDays,
+			Term:             term,
+			CumulativeShares: l.CumulativeShares.String(),
+			CumulativeResult: l.CumulativeResult.String(),
+		})
+	}
+	for _, s := range harvest.Sales {
+		sale := HarvestSale{
+			Stock:     encodeStock(s.Stock),
+			Depot:     s.Depot,
+			Shares:    s.Shares.String(),
+			Proceeds:  s.Proceeds.String(),
+			CostBasis: s.CostBasis.String(),
+			Loss:      s.Loss.String(),
+		}
+		if s.Lot != "" {
+			lot := s.Lot
+			sale.Lot = &lot
+		}
+		encoded.Sales = append(encoded.Sales, sale)
+	}
+	return encoded
+}
+
+type harvestResponse struct {
+	Harvest Harvest `json:"harvest"`
+}
+
+func (s *Server) harvestHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, ps httprouter.Params) error {
+	asOf, err := parseAsOf(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	target := decimal.Zero
+	if t := r.URL.Query().Get("target"); t != "" {
+		if target, err = decimal.NewFromString(t); err != nil || target.IsNegative() {
+			http.Error(w, fmt.Sprintf("invalid target %q", t), http.StatusBadRequest)
+			return nil
+		}
+	}
+
+	stocks, depots, err := s.selection(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	transactions, stats, err := cf.CalculateStats(stocks, depots)
+	if err != nil {
+		return err
+	}
+
+	portfolio := cf.PortfolioAt(transactions, stats, asOf)
+	harvest := EncodeHarvest(cf.CalculateHarvest(s.priceFunc, portfolio, asOf, target))
+	harvest.Currency = s.currency()
+	harvest.AsOf = asOf.Format("2006-01-02")
+
+	return json.NewEncoder(w).Encode(harvestResponse{
+		Harvest: harvest,
+	})
+}
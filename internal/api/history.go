@@ -0,0 +1,75 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/thcyron/cashflow/internal/cf"
+)
+
+type HistoryPoint struct {
+	Date           string   `json:"date"`
+	Value          string   `json:"value"`
+	Invested       string   `json:"invested"`
+	RealizedProfit string   `json:"realized_profit"`
+	Dividends      string   `json:"dividends"`
+	CashBalance    string   `json:"cash_balance"`
+	Return         *float64 `json:"return"`
+}
+
+func EncodeHistory(history []cf.HistoryPoint) []HistoryPoint {
+	encoded := []HistoryPoint{}
+	for _, p := range history {
+		encoded = append(encoded, HistoryPoint{
+			Date:           p.Date.Format("2006-01-02"),
+			Value:          p.Value.String(),
+			Invested:       p.Invested.String(),
+			RealizedProfit: p.RealizedProfit.String(),
+			Dividends:      p.Dividends.String(),
+			CashBalance:    p.CashBalance.String(),
+			Return:         encodeReturn(p.Return),
+		})
+	}
+	return encoded
+}
+
+type historyResponse struct {
+	Currency string         `json:"currency"`
+	Interval string         `json:"interval"`
+	History  []HistoryPoint `json:"history"`
+}
+
+func (s *Server) historyHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, ps httprouter.Params) error {
+	query := r.URL.Query()
+
+	interval, err := cf.ParseInterval(query.Get("interval"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	begin, end, err := parseRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	stocks, depots, err := s.selection(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	transactions, stats, err := cf.CalculateStats(stocks, depots)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(historyResponse{
+		Currency: s.currency(),
+		Interval: string(interval),
+		History:  EncodeHistory(cf.CalculateHistory(ctx, s.priceFunc, transactions, stats, begin, end, interval)),
+	})
+}
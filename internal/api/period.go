@@ -0,0 +1,116 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/thcyron/cashflow/internal/cf"
+)
+
+type PeriodPerformance struct {
+	Name        string      `json:"name"`
+	Begin       string      `json:"begin"`
+	End         string      `json:"end"`
+	Performance Performance `json:"performance"`
+	IRR         *float64    `json:"irr"`
+}
+
+func EncodePeriodPerformances(performances []cf.PeriodPerformance) []PeriodPerformance {
+	encoded := []PeriodPerformance{}
+	for _, p := range performances {
+		encoded = append(encoded, PeriodPerformance{
+			Name:        p.Period.Name,
+			Begin:       p.Period.Begin.Format("2006-01-02"),
+			End:         p.Period.End.Format("2006-01-02"),
+			Performance: EncodePerformance(p.Performance),
+			IRR:         encodeReturn(p.IRR),
+		})
+	}
+	return encoded
+}
+
+// parseAsOf parses the as_of query parameter, defaulting to today.
+func parseAsOf(r *http.Request) (time.Time, error) {
+	asOf := r.URL.Query().Get("as_of")
+	if asOf == "" {
+		return today(), nil
+	}
+	date, err := time.Parse("2006-01-02", asOf)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid as_of date %q", asOf)
+	}
+	return date, nil
+}
+
+// parseRange parses the query parameters from and to. A missing from
+// results in a zero time, a missing to in today.
+func parseRange(r *http.Request) (begin, end time.Time, err error) {
+	query := r.URL.Query()
+	end = today()
+	if from := query.Get("from"); from != "" {
+		if begin, err = time.Parse("2006-01-02", from); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid from date %q", from)
+		}
+	}
+	if to := query.Get("to"); to != "" {
+		if end, err = time.Parse("2006-01-02", to); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to date %q", to)
+		}
+	}
+	if end.Before(begin) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from date %q is after to date %q", query.Get("from"), query.Get("to"))
+	}
+	return begin, end, nil
+}
+
+// parsePeriods parses the periods requested by the query parameters period,
+// which may be repeated or contain a comma-separated list of named periods,
+// and from and to for a custom period.
+func parsePeriods(r *http.Request, today time.Time) ([]cf.Period, error) {
+	var (
+		query   = r.URL.Query()
+		periods []cf.Period
+	)
+
+	for _, value := range query["period"] {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name == "" {
+				continue
+			}
+			period, err := cf.ParsePeriod(name, today)
+			if err != nil {
+				return nil, err
+			}
+			periods = append(periods, period)
+		}
+	}
+
+	if from, to := query.Get("from"), query.Get("to"); from != "" || to != "" {
+		period := cf.Period{
+			Name: "custom",
+			End:  today,
+		}
+		if from != "" {
+			date, err := time.ParseInLocation("2006-01-02", from, today.Location())
+			if err != nil {
+				return nil, fmt.Errorf("invalid from date %q", from)
+			}
+			period.Begin = date
+		}
+		if to != "" {
+			date, err := time.ParseInLocation("2006-01-02", to, today.Location())
+			if err != nil {
+				return nil, fmt.Errorf("invalid to date %q", to)
+			}
+			period.End = date
+		}
+		if period.End.Before(period.Begin) {
+			return nil, fmt.Errorf("from date %q is after to date %q", from, to)
+		}
+		periods = append(periods, period)
+	}
+
+	return periods, nil
+}
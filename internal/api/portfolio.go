@@ -3,9 +3,10 @@ package api
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"math"
 	"net/http"
+	"sort"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 
@@ -13,57 +14,106 @@ import (
 )
 
 type Portfolio struct {
-	Stocks       []PortfolioStock `json:"stocks"`
-	Invested     string           `json:"invested"`
-	Value        string           `json:"value"`
-	Performances Performances     `json:"performances"`
+	AsOf           string              `json:"as_of"`
+	Currency       string              `json:"currency"`
+	Stocks         []PortfolioStock    `json:"stocks"`
+	Invested       string              `json:"invested"`
+	GrossInvested  string              `json:"gross_invested"`
+	Value          string              `json:"value"`
+	Fees           string              `json:"fees"`
+	Taxes          string              `json:"taxes"`
+	EstimatedTaxes string              `json:"estimated_taxes"`
+	AfterTaxIncome string              `json:"after_tax_income"`
+	Cash           []DepotCash         `json:"cash"`
+	CashBalance    string              `json:"cash_balance"`
+	TotalValue     string              `json:"total_value"`
+	Performances   Performances        `json:"performances"`
+	Periods        []PeriodPerformance `json:"periods"`
+	Risk           Risk                `json:"risk"`
+	Benchmarks     []Benchmark         `json:"benchmarks"`
+}
+
+type DepotCash struct {
+	Depot   string `json:"depot"`
+	Balance string `json:"balance"`
+}
+
+func encodeCash(portfolio cf.Portfolio) []DepotCash {
+	cash := []DepotCash{}
+	for depot, balance := range portfolio.Cash {
+		cash = append(cash, DepotCash{
+			Depot:   depot,
+			Balance: balance.String(),
+		})
+	}
+	sort.Slice(cash, func(i, j int) bool {
+		return cash[i].Depot < cash[j].Depot
+	})
+	return cash
 }
 
 type PortfolioStock struct {
 	Stock         Stock                 `json:"stock"`
 	Batches       []PortfolioStockBatch `json:"batches"`
 	Invested      string                `json:"invested"`
+	GrossInvested string                `json:"gross_invested"`
 	Value         string                `json:"value"`
 	Shares        string                `json:"shares"`
 	PricePerShare string                `json:"price_per_share"`
 	Performances  Performances          `json:"performances"`
+	Periods       []PeriodPerformance   `json:"periods"`
+	Risk          Risk                  `json:"risk"`
 }
 
 type PortfolioStockBatch struct {
+	Lot           string       `json:"lot"`
 	Depot         string       `json:"depot"`
 	Date          string       `json:"date"`
 	Shares        string       `json:"shares"`
 	PricePerShare string       `json:"price_per_share"`
 	Invested      string       `json:"invested"`
+	GrossInvested string       `json:"gross_invested"`
 	Value         string       `json:"value"`
 	Performances  Performances `json:"performances"`
 }
 
 type Performances struct {
-	Overall Performance `json:"overall"`
-	YTD     Performance `json:"ytd"`
-	Today   Performance `json:"today"`
-	IRR     *float64    `json:"irr"`
+	Overall     Performance `json:"overall"`
+	YTD         Performance `json:"ytd"`
+	Today       Performance `json:"today"`
+	IRR         *float64    `json:"irr"`
+	InvestorIRR *float64    `json:"investor_irr"`
 }
 
 func EncodePerformances(performances cf.Performances) Performances {
 	return Performances{
-		Overall: EncodePerformance(performances.Overall),
-		YTD:     EncodePerformance(performances.YTD),
-		Today:   EncodePerformance(performances.Today),
-		IRR:     encodeReturn(performances.IRR),
+		Overall:     EncodePerformance(performances.Overall),
+		YTD:         EncodePerformance(performances.YTD),
+		Today:       EncodePerformance(performances.Today),
+		IRR:         encodeReturn(performances.IRR),
+		InvestorIRR: encodeReturn(performances.InvestorIRR),
 	}
 }
 
 type Performance struct {
-	Return *float64 `json:"return"`
-	Profit string   `json:"profit"`
+	Return         *float64 `json:"return"`
+	Profit         string   `json:"profit"`
+	GrossReturn    *float64 `json:"gross_return"`
+	GrossProfit    string   `json:"gross_profit"`
+	PriceEffect    string   `json:"price_effect"`
+	CurrencyEffect string   `json:"currency_effect"`
+	TWR            *float64 `json:"twr"`
 }
 
 func EncodePerformance(performance cf.Performance) Performance {
 	return Performance{
-		Return: encodeReturn(performance.Return),
-		Profit: performance.Profit.String(),
+		Return:         encodeReturn(performance.Return),
+		Profit:         performance.Profit.String(),
+		GrossReturn:    encodeReturn(performance.GrossReturn),
+		GrossProfit:    performance.GrossProfit.String(),
+		PriceEffect:    performance.PriceEffect.String(),
+		CurrencyEffect: performance.CurrencyEffect.String(),
+		TWR:            encodeReturn(performance.TWR),
 	}
 }
 
@@ -79,6 +129,7 @@ func EncodePortfolioStock(stock *cf.Stock, portfolioStock *cf.PortfolioStock) Po
 		Stock:         encodeStock(stock),
 		Batches:       []PortfolioStockBatch{},
 		Invested:      portfolioStock.Invested().String(),
+		GrossInvested: portfolioStock.GrossInvested().String(),
 		Shares:        portfolioStock.Shares().String(),
 		PricePerShare: portfolioStock.PricePerShare().String(),
 	}
@@ -90,11 +141,13 @@ func EncodePortfolioStock(stock *cf.Stock, portfolioStock *cf.PortfolioStock) Po
 
 func EncodePortfolioStockBatch(batch cf.PortfolioStockBatch) PortfolioStockBatch {
 	return PortfolioStockBatch{
+		Lot:           batch.Lot,
 		Depot:         batch.Depot,
 		Date:          batch.Date.Format("2006-01-02"),
 		Shares:        batch.Shares.String(),
 		PricePerShare: batch.PricePerShare.String(),
 		Invested:      batch.Invested().String(),
+		GrossInvested: batch.GrossInvested().String(),
 	}
 }
 
@@ -103,51 +156,100 @@ type portfolioResponse struct {
 }
 
 func (s *Server) portfolioHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, ps httprouter.Params) error {
-	stocks, err := s.repo.Stocks(ctx)
+	asOf, err := parseAsOf(r)
 	if err != nil {
-		return fmt.Errorf("fetching stocks: %w", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	periods, err := parsePeriods(r, asOf)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	stocks, err := s.stocks(ctx)
+	if err != nil {
+		return err
 	}
 
+	depots, err := s.depots(ctx)
+	if err != nil {
+		return err
+	}
+
+	// A single stock is calculated without the cash of the depots, but
+	// with their lot methods and tax engines.
+	var (
+		selected       = stocks
+		calculateStats = cf.CalculateStats
+	)
 	if symbol := r.URL.Query().Get("stock"); symbol != "" {
-		var found *cf.Stock
-		for _, stock := range stocks {
-			if stock.Symbol == symbol {
-				found = stock
-				break
-			}
-		}
-		if found != nil {
-			stocks = []*cf.Stock{found}
-		} else {
-			stocks = []*cf.Stock{}
-		}
+		selected = filterStock(stocks, symbol)
+		calculateStats = cf.CalculateStockStats
 	}
 
-	transactions, stats, err := cf.CalculateStats(stocks)
+	transactions, stats, err := calculateStats(selected, depots)
 	if err != nil {
 		return err
 	}
 
-	portfolio := cf.BuildPortfolio(stocks)
-	performances := cf.CalculatePerformances(ctx, s.priceFunc, transactions, stats)
+	portfolio := cf.PortfolioAt(transactions, stats, asOf)
+	performances := cf.CalculatePerformances(ctx, s.priceFunc, s.converter, transactions, stats, asOf)
+	value := portfolio.Invested().Add(performances.Overall.Profit)
+
+	periodPerformances := cf.CalculatePeriodPerformances(ctx, s.priceFunc, s.converter, transactions, stats, periods)
+
+	benchmarks := []Benchmark{}
+	for _, benchmark := range s.benchmarks {
+		benchmark, err := cf.ResolveBenchmark(benchmark, stocks)
+		if err != nil {
+			s.logger.Log("msg", "skipping benchmark", "err", err)
+			continue
+		}
+		comparison, err := cf.CompareBenchmark(ctx, s.priceFunc, s.converter, benchmark, transactions, performances, periodPerformances, asOf)
+		if err != nil {
+			s.logger.Log("msg", "skipping benchmark", "err", err)
+			continue
+		}
+		benchmarks = append(benchmarks, EncodeBenchmark(comparison))
+	}
 
 	encodedPortfolio := Portfolio{
-		Stocks:       []PortfolioStock{},
-		Invested:     portfolio.Invested().String(),
-		Value:        portfolio.Invested().Add(performances.Overall.Profit).String(),
-		Performances: EncodePerformances(performances),
+		AsOf:           asOf.Format("2006-01-02"),
+		Currency:       s.currency(),
+		Stocks:         []PortfolioStock{},
+		Invested:       portfolio.Invested().String(),
+		GrossInvested:  portfolio.GrossInvested().String(),
+		Value:          value.String(),
+		Fees:           portfolio.Fees().String(),
+		Taxes:          portfolio.Taxes().String(),
+		EstimatedTaxes: portfolio.EstimatedTaxes().String(),
+		AfterTaxIncome: portfolio.AfterTaxIncome().String(),
+		Cash:           encodeCash(portfolio),
+		CashBalance:    portfolio.CashBalance().String(),
+		TotalValue:     value.Add(portfolio.CashBalance()).String(),
+		Performances:   EncodePerformances(performances),
+		Periods:        EncodePeriodPerformances(periodPerformances),
+		Risk:           EncodeRisk(cf.CalculateRisk(ctx, s.priceFunc, transactions, stats, time.Time{}, asOf, s.riskFreeRate)),
+		Benchmarks:     benchmarks,
 	}
-	for stock, portfolioStock := range portfolio {
-		if portfolioStock.Shares().IsPositive() {
+	for stock, portfolioStock := range portfolio.Stocks {
+		if !portfolioStock.Shares().IsZero() {
 			encodedPortfolioStock := EncodePortfolioStock(stock, portfolioStock)
 
-			stockTransactions, stockStats, err := cf.CalculateStats([]*cf.Stock{stock})
+			// Stocks which received their shares by corporate actions
+			// need the stocks they are related to.
+			stockTransactions, stockStats, err := cf.CalculateStockStats(cf.Related(stocks, stock), depots)
 			if err != nil {
 				return err
 			}
+			stockTransactions, stockStats = cf.OwnStats(stock, stockTransactions, stockStats)
 
-			performances := cf.CalculatePerformances(ctx, s.priceFunc, stockTransactions, stockStats)
+			performances := cf.CalculatePerformances(ctx, s.priceFunc, s.converter, stockTransactions, stockStats, asOf)
 			encodedPortfolioStock.Performances = EncodePerformances(performances)
+			encodedPortfolioStock.Periods = EncodePeriodPerformances(cf.CalculatePeriodPerformances(ctx, s.priceFunc, s.converter, stockTransactions, stockStats, periods))
+			encodedPortfolioStock.Risk = EncodeRisk(cf.CalculateRisk(ctx, s.priceFunc, stockTransactions, stockStats, time.Time{}, asOf, s.riskFreeRate))
 
 			encodedPortfolioStock.Value = portfolioStock.Invested().Add(performances.Overall.Profit).String()
 			encodedPortfolio.Stocks = append(encodedPortfolio.Stocks, encodedPortfolioStock)
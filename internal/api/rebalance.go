@@ -0,0 +1,141 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/shopspring/decimal"
+
+	"github.com/thcyron/cashflow/internal/cf"
+)
+
+type RebalanceTarget struct {
+	Key         string  `json:"key"`
+	Value       string  `json:"value"`
+	Weight      float64 `json:"weight"`
+	TargetValue string  `json:"target_value"`
+	Target      float64 `json:"target"`
+	Drift       float64 `json:"drift"`
+}
+
+type RebalanceTrade struct {
+	Stock  Stock  `json:"stock"`
+	Type   string `json:"type"`
+	Shares string `json:"shares"`
+	Amount string `json:"amount"`
+}
+
+type Rebalance struct {
+	Currency    string            `json:"currency"`
+	AsOf        string            `json:"as_of"`
+	By          string            `json:"by"`
+	Total       string            `json:"total"`
+	Cash        string            `json:"cash"`
+	Targets     []RebalanceTarget `json:"targets"`
+	Trades      []RebalanceTrade  `json:"trades"`
+	Unallocated string            `json:"unallocated"`
+}
+
+func EncodeRebalance(rebalance cf.Rebalance) Rebalance {
+	encoded := Rebalance{
+		By:          string(rebalance.Dimension),
+		Total:       rebalance.Total.String(),
+		Cash:        rebalance.Cash.String(),
+		Targets:     []RebalanceTarget{},
+		Trades:      []RebalanceTrade{},
+		Unallocated: rebalance.Unallocated.String(),
+	}
+	for _, t := range rebalance.Targets {
+		encoded.Targets = append(encoded.Targets, RebalanceTarget{
+			Key:         t.Key,
+			Value:       t.Value.String(),
+			Weight:      t.Weight,
+			TargetValue: t.TargetValue.String(),
+			Target:      t.Target,
+			Drift:       t.Drift,
+		})
+	}
+	for _, t := range rebalance.Trades {
+		encoded.Trades = append(encoded.Trades, RebalanceTrade{
+			Stock:  encodeStock(t.Stock),
+			Type:   string(t.Type),
+			Shares: t.Shares.String(),
+			Amount: t.Amount.String(),
+		})
+	}
+	return encoded
+}
+
+type rebalanceResponse struct {
+	Rebalance Rebalance `json:"rebalance"`
+}
+
+func (s *Server) rebalanceHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, ps httprouter.Params) error {
+	asOf, err := parseAsOf(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	query := r.URL.Query()
+
+	by := cf.AssetClass
+	if b := query.Get("by"); b != "" {
+		if by, err = cf.ParseDimension(b); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil
+		}
+	}
+
+	var options cf.RebalanceOptions
+	if c := query.Get("cash"); c != "" {
+		if options.Cash, err = decimal.NewFromString(c); err != nil || options.Cash.IsNegative() {
+			http.Error(w, fmt.Sprintf("invalid cash %q", c), http.StatusBadRequest)
+			return nil
+		}
+	}
+	if b := query.Get("buy_only"); b != "" {
+		if options.BuyOnly, err = strconv.ParseBool(b); err != nil {
+			http.Error(w, fmt.Sprintf("invalid buy_only %q", b), http.StatusBadRequest)
+			return nil
+		}
+	}
+	if m := query.Get("min_trade"); m != "" {
+		if options.MinTrade, err = decimal.NewFromString(m); err != nil || options.MinTrade.IsNegative() {
+			http.Error(w, fmt.Sprintf("invalid min_trade %q", m), http.StatusBadRequest)
+			return nil
+		}
+	}
+
+	targets, err := s.repo.Targets(ctx)
+	if err != nil {
+		return err
+	}
+	if len(targets[by]) == 0 {
+		http.Error(w, fmt.Sprintf("no targets by %s", by), http.StatusBadRequest)
+		return nil
+	}
+
+	stocks, depots, err := s.selection(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	transactions, stats, err := cf.CalculateStats(stocks, depots)
+	if err != nil {
+		return err
+	}
+
+	portfolio := cf.PortfolioAt(transactions, stats, asOf)
+	rebalance := EncodeRebalance(cf.CalculateRebalance(s.priceFunc, portfolio, asOf, by, targets[by], options))
+	rebalance.Currency = s.currency()
+	rebalance.AsOf = asOf.Format("2006-01-02")
+
+	return json.NewEncoder(w).Encode(rebalanceResponse{
+		Rebalance: rebalance,
+	})
+}
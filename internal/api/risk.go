@@ -0,0 +1,28 @@
+package api
+
+import (
+	"github.com/thcyron/cashflow/internal/cf"
+)
+
+type Risk struct {
+	Volatility  *float64 `json:"volatility"`
+	MaxDrawdown *float64 `json:"max_drawdown"`
+	Peak        *string  `json:"peak"`
+	Trough      *string  `json:"trough"`
+	Sharpe      *float64 `json:"sharpe"`
+	Sortino     *float64 `json:"sortino"`
+}
+
+func EncodeRisk(risk cf.Risk) Risk {
+	encoded := Risk{
+		Volatility:  encodeReturn(risk.Volatility),
+		MaxDrawdown: encodeReturn(risk.MaxDrawdown),
+		Sharpe:      encodeReturn(risk.Sharpe),
+		Sortino:     encodeReturn(risk.Sortino),
+	}
+	if !risk.Peak.IsZero() {
+		peak, trough := risk.Peak.Format("2006-01-02"), risk.Trough.Format("2006-01-02")
+		encoded.Peak, encoded.Trough = &peak, &trough
+	}
+	return encoded
+}
@@ -2,8 +2,10 @@ package api
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/julienschmidt/httprouter"
@@ -15,23 +17,44 @@ type Server struct {
 	logger        log.Logger
 	repo          cf.Repository
 	priceProvider cf.PriceProvider
+	converter     *cf.Converter
+	benchmarks    []*cf.Stock
+	riskFreeRate  float64
 
-	mu        sync.RWMutex
-	priceFunc cf.PriceFunc
-	router    *httprouter.Router
+	// priceFunc returns prices in the base currency, rawPriceFunc in the
+	// currencies of the stocks.
+	mu           sync.RWMutex
+	priceFunc    cf.PriceFunc
+	rawPriceFunc cf.PriceFunc
+	router       *httprouter.Router
 }
 
-func New(logger log.Logger, repo cf.Repository, priceFunc cf.PriceFunc) *Server {
+// New returns a new API server. All amounts are converted into the base
+// currency of converter, which may be nil if all stocks share one currency.
+// The portfolio is compared with each of benchmarks. Sharpe and Sortino
+// ratios are calculated against the annual riskFreeRate.
+func New(logger log.Logger, repo cf.Repository, priceFunc cf.PriceFunc, converter *cf.Converter, benchmarks []*cf.Stock, riskFreeRate float64) *Server {
 	s := &Server{
-		logger:    logger,
-		repo:      repo,
-		priceFunc: priceFunc,
+		logger:       logger,
+		repo:         repo,
+		converter:    converter,
+		benchmarks:   benchmarks,
+		riskFreeRate: riskFreeRate,
+		priceFunc:    converter.PriceFunc(cf.Redeemed(priceFunc)),
+		rawPriceFunc: priceFunc,
 	}
 
 	s.router = httprouter.New()
 	s.router.GET("/stocks", s.wrap(s.stocksHandler))
-	s.router.GET("/stocks/:isin", s.wrap(s.stockHandler))
+	s.router.GET("/stocks/:id", s.wrap(s.stockHandler))
 	s.router.GET("/portfolio", s.wrap(s.portfolioHandler))
+	s.router.GET("/portfolio/history", s.wrap(s.historyHandler))
+	s.router.GET("/dividends", s.wrap(s.dividendsHandler))
+	s.router.GET("/gains", s.wrap(s.gainsHandler))
+	s.router.GET("/harvest", s.wrap(s.harvestHandler))
+	s.router.GET("/allocation", s.wrap(s.allocationHandler))
+	s.router.GET("/rebalance", s.wrap(s.rebalanceHandler))
+	s.router.GET("/taxes/vorabpauschale", s.wrap(s.vorabpauschaleHandler))
 
 	return s
 }
@@ -40,6 +63,111 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.router.ServeHTTP(w, r)
 }
 
+// stocks returns all stocks with amounts converted into the base currency.
+func (s *Server) stocks(ctx context.Context) ([]*cf.Stock, error) {
+	stocks, err := s.repo.Stocks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetching stocks: %w", err)
+	}
+	converted, err := s.converter.Stocks(stocks)
+	if err != nil {
+		return nil, fmt.Errorf("converting stocks: %w", err)
+	}
+	return converted, nil
+}
+
+// depots returns all depots with amounts converted into the base currency.
+func (s *Server) depots(ctx context.Context) ([]*cf.Depot, error) {
+	depots, err := s.repo.Depots(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetching depots: %w", err)
+	}
+	converted, err := s.converter.Depots(depots)
+	if err != nil {
+		return nil, fmt.Errorf("converting depots: %w", err)
+	}
+	return converted, nil
+}
+
+// selection returns the stocks and depots selected by the query parameters
+// stock, a stock symbol, and depot, a depot name.
+func (s *Server) selection(ctx context.Context, r *http.Request) ([]*cf.Stock, []*cf.Depot, error) {
+	stocks, err := s.stocks(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	depots, err := s.depots(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	query := r.URL.Query()
+	if symbol := query.Get("stock"); symbol != "" {
+		stocks = filterStock(stocks, symbol)
+	}
+	if depot := query.Get("depot"); depot != "" {
+		if stocks, depots, err = filterDepot(stocks, depots, depot); err != nil {
+			return nil, nil, err
+		}
+	}
+	if query.Get("stock") != "" {
+		depots = nil
+	}
+	return stocks, depots, nil
+}
+
+// filterStock returns the stock with the given symbol, if any, and the stocks
+// linked to it by corporate actions.
+func filterStock(stocks []*cf.Stock, symbol string) []*cf.Stock {
+	for _, stock := range stocks {
+		if stock.Symbol == symbol {
+			return cf.Related(stocks, stock)
+		}
+	}
+	return []*cf.Stock{}
+}
+
+// filterDepot returns the stocks and depots restricted to the transactions
+// in the named depot.
+func filterDepot(stocks []*cf.Stock, depots []*cf.Depot, name string) ([]*cf.Stock, []*cf.Depot, error) {
+	// Transfers move the lots chosen by the lot methods of the depots.
+	_, stats, err := cf.CalculateStockStats(stocks, depots)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filteredStocks := []*cf.Stock{}
+	for _, stock := range stocks {
+		stock = stock.ForDepot(name, stats)
+		for _, t := range stock.Transactions {
+			if t.Type != cf.Split {
+				filteredStocks = append(filteredStocks, stock)
+				break
+			}
+		}
+	}
+	var filteredDepots []*cf.Depot
+	for _, depot := range depots {
+		if depot.Name == name {
+			filteredDepots = append(filteredDepots, depot)
+		}
+	}
+	return filteredStocks, filteredDepots, nil
+}
+
+func (s *Server) currency() string {
+	if s.converter == nil {
+		return ""
+	}
+	return s.converter.Base
+}
+
+func today() time.Time {
+	now := time.Now()
+	return cf.Date(now.Year(), int(now.Month()), now.Day())
+}
+
 type Handler func(ctx context.Context, w http.ResponseWriter, r *http.Request, ps httprouter.Params) error
 
 func (s *Server) wrap(handler Handler) httprouter.Handle {
@@ -13,9 +13,33 @@ import (
 )
 
 type Stock struct {
-	Name   string  `json:"name"`
-	ISIN   string  `json:"isin"`
-	Symbol *string `json:"symbol"`
+	Name       string   `json:"name"`
+	ISIN       string   `json:"isin"`
+	Symbol     *string  `json:"symbol"`
+	Currency   *string  `json:"currency"`
+	FundType   *string  `json:"fund_type"`
+	AssetClass *string  `json:"asset_class"`
+	Sector     *string  `json:"sector"`
+	Country    *string  `json:"country"`
+	Tags       []string `json:"tags"`
+	Kind       *string  `json:"kind"`
+	Bond       *Bond    `json:"bond"`
+	Option     *Option  `json:"option"`
+}
+
+type Bond struct {
+	Nominal   string  `json:"nominal"`
+	Coupon    string  `json:"coupon"`
+	Frequency int     `json:"frequency"`
+	Maturity  *string `json:"maturity"`
+}
+
+type Option struct {
+	Underlying string  `json:"underlying"`
+	Type       string  `json:"type"`
+	Strike     string  `json:"strike"`
+	Expiry     *string `json:"expiry"`
+	Multiplier string  `json:"multiplier"`
 }
 
 func encodeStock(stock *cf.Stock) Stock {
@@ -26,6 +50,53 @@ func encodeStock(stock *cf.Stock) Stock {
 	if stock.Symbol != "" {
 		encodedStock.Symbol = &stock.Symbol
 	}
+	if stock.Currency != "" {
+		encodedStock.Currency = &stock.Currency
+	}
+	if stock.FundType.IsFund() {
+		fundType := string(stock.FundType)
+		encodedStock.FundType = &fundType
+	}
+	if stock.AssetClass != "" {
+		encodedStock.AssetClass = &stock.AssetClass
+	}
+	if stock.Sector != "" {
+		encodedStock.Sector = &stock.Sector
+	}
+	if stock.Country != "" {
+		encodedStock.Country = &stock.Country
+	}
+	encodedStock.Tags = stock.Tags
+	if encodedStock.Tags == nil {
+		encodedStock.Tags = []string{}
+	}
+	if stock.Kind != cf.StockKind {
+		kind := string(stock.Kind)
+		encodedStock.Kind = &kind
+	}
+	if stock.Kind == cf.BondKind {
+		encodedStock.Bond = &Bond{
+			Nominal:   stock.Nominal.String(),
+			Coupon:    stock.Coupon.String(),
+			Frequency: stock.Frequency,
+		}
+		if !stock.Maturity.IsZero() {
+			maturity := stock.Maturity.Format("2006-01-02")
+			encodedStock.Bond.Maturity = &maturity
+		}
+	}
+	if stock.IsDerivative() {
+		encodedStock.Option = &Option{
+			Underlying: stock.Underlying,
+			Type:       string(stock.OptionType),
+			Strike:     stock.Strike.String(),
+			Multiplier: stock.Multiplier().String(),
+		}
+		if !stock.Expiry.IsZero() {
+			expiry := stock.Expiry.Format("2006-01-02")
+			encodedStock.Option.Expiry = &expiry
+		}
+	}
 	return encodedStock
 }
 
@@ -49,38 +120,64 @@ func (s *Server) stocksHandler(ctx context.Context, w http.ResponseWriter, r *ht
 }
 
 type stockResponse struct {
+	AsOf          string               `json:"as_of"`
+	Currency      string               `json:"currency"`
 	Stock         Stock                `json:"stock"`
 	Transactions  []Transaction        `json:"transactions"`
 	Performances  Performances         `json:"performances"`
+	Periods       []PeriodPerformance  `json:"periods"`
+	Risk          Risk                 `json:"risk"`
 	Batches       []stockResponseBatch `json:"batches"`
 	Invested      string               `json:"invested"`
+	GrossInvested string               `json:"gross_invested"`
 	Value         string               `json:"value"`
 	Shares        string               `json:"shares"`
 	PricePerShare string               `json:"price_per_share"`
+	Fees          string               `json:"fees"`
+	Taxes         string               `json:"taxes"`
+
+	// AccruedInterest and YieldToMaturity are only set for bonds.
+	AccruedInterest *string  `json:"accrued_interest"`
+	YieldToMaturity *float64 `json:"yield_to_maturity"`
 }
 
 type stockResponseBatch struct {
+	Lot           string       `json:"lot"`
 	Depot         string       `json:"depot"`
 	Date          string       `json:"date"`
 	Shares        string       `json:"shares"`
 	Invested      string       `json:"invested"`
+	GrossInvested string       `json:"gross_invested"`
 	Value         string       `json:"value"`
 	PricePerShare string       `json:"price_per_share"`
 	Performances  Performances `json:"performances"`
 }
 
 func (s *Server) stockHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, ps httprouter.Params) error {
-	stocks, err := s.repo.Stocks(ctx)
+	asOf, err := parseAsOf(r)
 	if err != nil {
-		return fmt.Errorf("fetching stocks: %w", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
 	}
 
+	periods, err := parsePeriods(r, asOf)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	stocks, err := s.stocks(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Stocks are looked up by ISIN or, without an ISIN, by symbol.
 	var (
-		isin  = ps.ByName("isin")
+		id    = ps.ByName("id")
 		stock *cf.Stock
 	)
 	for _, s := range stocks {
-		if s.ISIN == isin {
+		if s.ID() == id {
 			stock = s
 			break
 		}
@@ -90,52 +187,103 @@ func (s *Server) stockHandler(ctx context.Context, w http.ResponseWriter, r *htt
 		return nil
 	}
 
-	transactions, stats, err := cf.CalculateStats([]*cf.Stock{stock})
+	depots, err := s.depots(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Stocks linked by corporate actions share their history.
+	transactions, stats, err := cf.CalculateStockStats(cf.Related(stocks, stock), depots)
+	if err != nil {
+		return err
+	}
+
+	// Estimated taxes depend on the allowances and losses of all stocks in a
+	// depot, so transactions are encoded with the stats of the portfolio.
+	_, portfolioStats, err := cf.CalculateStats(stocks, depots)
 	if err != nil {
 		return err
 	}
 
 	encodedTransactions := []Transaction{}
-	for _, transaction := range stock.Transactions {
-		encodedTransactions = append(encodedTransactions, encodeTransaction(transaction, stats[transaction]))
+	for _, transaction := range transactions {
+		if transaction.Date.After(asOf) {
+			continue
+		}
+		encodedTransactions = append(encodedTransactions, encodeTransaction(transaction, portfolioStats[transaction]))
 	}
 
-	performances := cf.CalculatePerformances(ctx, s.priceFunc, transactions, stats)
+	// The performance is that of the stock alone, like its value.
+	ownTransactions, ownStats := cf.OwnStats(stock, transactions, stats)
+	performances := cf.CalculatePerformances(ctx, s.priceFunc, s.converter, ownTransactions, ownStats, asOf)
 
-	portfolio := cf.BuildPortfolio([]*cf.Stock{stock})[stock]
+	portfolio := cf.PortfolioAt(transactions, stats, asOf).Stocks[stock]
+	if portfolio == nil {
+		portfolio = &cf.PortfolioStock{}
+	}
 	batches := []stockResponseBatch{}
 
 	for _, batch := range portfolio.Batches {
 		var (
 			invested = batch.Invested()
-			value    = s.priceFunc(stock, time.Now()).Mul(batch.Shares)
+			value    = cf.UnitPrice(s.priceFunc, stock, asOf).Mul(batch.Shares)
 		)
 
 		batchStats, err := batch.Transactions.Stats()
 		if err != nil {
 			return err
 		}
-		batchPerformances := cf.CalculatePerformances(ctx, s.priceFunc, batch.Transactions, batchStats)
+		batchPerformances := cf.CalculatePerformances(ctx, s.priceFunc, s.converter, batch.Transactions, batchStats, asOf)
 
 		batches = append(batches, stockResponseBatch{
+			Lot:           batch.Lot,
 			Depot:         batch.Depot,
 			Date:          batch.Date.Format("2006-01-02"),
 			Shares:        batch.Shares.String(),
 			Invested:      invested.String(),
+			GrossInvested: batch.GrossInvested().String(),
 			Value:         value.String(),
 			PricePerShare: batch.PricePerShare.String(),
 			Performances:  EncodePerformances(batchPerformances),
 		})
 	}
 
+	var (
+		accruedInterest *string
+		yieldToMaturity *float64
+	)
+	if stock.Kind == cf.BondKind {
+		interest, err := s.converter.Convert(stock.AccruedInterest(portfolio.Shares(), asOf), stock.Currency, asOf)
+		if err != nil {
+			return fmt.Errorf("converting accrued interest: %w", err)
+		}
+		a := interest.String()
+		accruedInterest = &a
+		// The yield compares the price with the nominal value and coupons,
+		// which are in the currency of the bond.
+		if ytm, err := stock.YieldToMaturity(s.rawPriceFunc(stock, asOf), asOf); err == nil {
+			yieldToMaturity = encodeReturn(ytm)
+		}
+	}
+
 	return json.NewEncoder(w).Encode(stockResponse{
+		AsOf:          asOf.Format("2006-01-02"),
+		Currency:      s.currency(),
 		Stock:         encodeStock(stock),
 		Transactions:  encodedTransactions,
 		Performances:  EncodePerformances(performances),
+		Periods:       EncodePeriodPerformances(cf.CalculatePeriodPerformances(ctx, s.priceFunc, s.converter, ownTransactions, ownStats, periods)),
+		Risk:          EncodeRisk(cf.CalculateRisk(ctx, s.priceFunc, ownTransactions, ownStats, time.Time{}, asOf, s.riskFreeRate)),
 		Batches:       batches,
 		Invested:      portfolio.Invested().String(),
-		Value:         portfolio.Invested().Add(performances.Overall.Profit).String(),
+		GrossInvested: portfolio.GrossInvested().String(),
+		Value:         cf.UnitPrice(s.priceFunc, stock, asOf).Mul(portfolio.Shares()).String(),
 		Shares:        portfolio.Shares().String(),
 		PricePerShare: portfolio.PricePerShare().String(),
+		Fees:          portfolio.Fees.String(),
+		Taxes:         portfolio.Taxes.String(),
+
+		AccruedInterest: accruedInterest,
+		YieldToMaturity: yieldToMaturity,
 	})
 }
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/thcyron/cashflow/internal/cf"
+)
+
+type Vorabpauschale struct {
+	Stock   Stock  `json:"stock"`
+	Depot   string `json:"depot"`
+	Shares  string `json:"shares"`
+	Amount  string `json:"amount"`
+	Taxable string `json:"taxable"`
+	Tax     string `json:"tax"`
+}
+
+func EncodeVorabpauschale(v cf.Vorabpauschale) Vorabpauschale {
+	return Vorabpauschale{
+		Stock:   encodeStock(v.Stock),
+		Depot:   v.Depot,
+		Shares:  v.Shares.String(),
+		Amount:  v.Amount.String(),
+		Taxable: v.Taxable.String(),
+		Tax:     v.Tax.String(),
+	}
+}
+
+type vorabpauschaleResponse struct {
+	Currency       string           `json:"currency"`
+	Year           int              `json:"year"`
+	Vorabpauschale []Vorabpauschale `json:"vorabpauschale"`
+}
+
+func (s *Server) vorabpauschaleHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, ps httprouter.Params) error {
+	year := today().Year() - 1
+	if y := r.URL.Query().Get("year"); y != "" {
+		var err error
+		if year, err = strconv.Atoi(y); err != nil {
+			http.Error(w, fmt.Sprintf("invalid year %q", y), http.StatusBadRequest)
+			return nil
+		}
+	}
+
+	stocks, depots, err := s.selection(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	transactions, stats, err := cf.CalculateStats(stocks, depots)
+	if err != nil {
+		return err
+	}
+
+	response := vorabpauschaleResponse{
+		Currency:       s.currency(),
+		Year:           year,
+		Vorabpauschale: []Vorabpauschale{},
+	}
+	for _, v := range cf.CalculateVorabpauschale(s.priceFunc, transactions, stats, year) {
+		response.Vorabpauschale = append(response.Vorabpauschale, EncodeVorabpauschale(v))
+	}
+	return json.NewEncoder(w).Encode(response)
+}
@@ -7,20 +7,93 @@ import (
 )
 
 type Transaction struct {
-	Date   string `json:"date"`
-	Amount string `json:"amount"`
-	Shares string `json:"shares"`
-	Depot  string `json:"depot"`
-	Stats  Stats  `json:"stats"`
+	ISIN     string  `json:"isin"`
+	Symbol   *string `json:"symbol"`
+	Type     string  `json:"type"`
+	Date     string  `json:"date"`
+	Amount   string  `json:"amount"`
+	Shares   string  `json:"shares"`
+	Currency *string `json:"currency"`
+	Fees     string  `json:"fees"`
+	Taxes    string  `json:"taxes"`
+	Ratio    *string `json:"ratio"`
+	Depot    string  `json:"depot"`
+	Lot      *string `json:"lot"`
+	Stats    Stats   `json:"stats"`
+
+	ReinvestedShares *string `json:"reinvested_shares"`
+	ToDepot          *string `json:"to_depot"`
+	Source           *string `json:"source"`
+	CostShare        *string `json:"cost_share"`
+	AccruedInterest  *string `json:"accrued_interest"`
 }
 
 func encodeTransaction(transaction *cf.Transaction, stats cf.Stats) Transaction {
+	var ratio *string
+	if transaction.Type == cf.Split || transaction.Type == cf.Merger || transaction.Type == cf.SpinOff {
+		r := transaction.Ratio.String()
+		ratio = &r
+	}
+	var lot *string
+	if transaction.Lot != "" {
+		lot = &transaction.Lot
+	}
+	var currency *string
+	if transaction.Currency != "" {
+		currency = &transaction.Currency
+	}
+	var source, costShare *string
+	if transaction.Source != "" {
+		source = &transaction.Source
+	}
+	if transaction.Type == cf.SpinOff {
+		c := transaction.CostShare.String()
+		costShare = &c
+	}
+	var toDepot *string
+	if transaction.ToDepot != "" {
+		toDepot = &transaction.ToDepot
+	}
+	var accruedInterest *string
+	if !transaction.AccruedInterest.IsZero() {
+		a := transaction.AccruedInterest.String()
+		accruedInterest = &a
+	}
+	var reinvestedShares *string
+	if transaction.Reinvested() {
+		s := transaction.ReinvestedShares.String()
+		reinvestedShares = &s
+	}
+	var (
+		isin   string
+		symbol *string
+	)
+	if transaction.Stock != nil {
+		isin = transaction.Stock.ISIN
+		if transaction.Stock.Symbol != "" {
+			symbol = &transaction.Stock.Symbol
+		}
+	}
 	return Transaction{
-		Date:   transaction.Date.Format("2006-01-02"),
-		Amount: transaction.Amount.String(),
-		Shares: transaction.Shares.String(),
-		Depot:  transaction.Depot,
-		Stats:  encodeStats(stats),
+		ISIN:     isin,
+		Symbol:   symbol,
+		Type:     string(transaction.Type),
+		Date:     transaction.Date.Format("2006-01-02"),
+		Amount:   transaction.Amount.String(),
+		Shares:   transaction.Shares.String(),
+		Currency: currency,
+		Fees:     transaction.Fees.String(),
+		Taxes:    transaction.Taxes.String(),
+		Ratio:    ratio,
+		Depot:    transaction.Depot,
+		Lot:      lot,
+		Stats:    encodeStats(stats),
+
+		ReinvestedShares: reinvestedShares,
+		ToDepot:          toDepot,
+		Source:           source,
+		CostShare:        costShare,
+		AccruedInterest:  accruedInterest,
 	}
 }
 
@@ -31,40 +104,60 @@ type Stats struct {
 }
 
 type StatsDividend struct {
-	Return float64 `json:"return"`
+	Return         float64         `json:"return"`
+	GrossReturn    float64         `json:"gross_return"`
+	Tax            decimal.Decimal `json:"tax"`
+	AfterTaxAmount decimal.Decimal `json:"after_tax_amount"`
 }
 
 type StatsBuy struct {
-	PricePerShare decimal.Decimal `json:"price_per_share"`
+	PricePerShare         decimal.Decimal `json:"price_per_share"`
+	GrossPricePerShare    decimal.Decimal `json:"gross_price_per_share"`
+	AdjustedPricePerShare decimal.Decimal `json:"adjusted_price_per_share"`
 }
 
 type StatsSell struct {
-	Return        float64         `json:"return"`
-	Profit        decimal.Decimal `json:"profit"`
-	PricePerShare decimal.Decimal `json:"price_per_share"`
+	Return         float64         `json:"return"`
+	Profit         decimal.Decimal `json:"profit"`
+	GrossReturn    float64         `json:"gross_return"`
+	GrossProfit    decimal.Decimal `json:"gross_profit"`
+	PricePerShare  decimal.Decimal `json:"price_per_share"`
+	Tax            decimal.Decimal `json:"tax"`
+	AfterTaxProfit decimal.Decimal `json:"after_tax_profit"`
 }
 
 func encodeStats(stats cf.Stats) Stats {
-	switch {
-	case stats.Transaction.Shares.IsPositive():
+	switch stats.Transaction.Type {
+	case cf.Sell, cf.Cover, cf.Exercise, cf.Assignment:
 		return Stats{
 			Sell: &StatsSell{
-				Return:        stats.Sell.Return,
-				Profit:        stats.Sell.Profit,
-				PricePerShare: stats.Sell.PricePerShare,
+				Return:         stats.Sell.Return,
+				Profit:         stats.Sell.Profit,
+				GrossReturn:    stats.Sell.GrossReturn,
+				GrossProfit:    stats.Sell.GrossProfit,
+				PricePerShare:  stats.Sell.PricePerShare,
+				Tax:            stats.Sell.Tax,
+				AfterTaxProfit: stats.Sell.AfterTaxProfit,
 			},
 		}
-	case stats.Transaction.Shares.IsNegative():
+	case cf.Buy:
 		return Stats{
 			Buy: &StatsBuy{
-				PricePerShare: stats.Buy.PricePerShare,
+				PricePerShare:         stats.Buy.PricePerShare,
+				GrossPricePerShare:    stats.Buy.GrossPricePerShare,
+				AdjustedPricePerShare: stats.Buy.AdjustedPricePerShare,
 			},
 		}
-	default:
+	case cf.Dividend, cf.Coupon:
 		return Stats{
 			Dividend: &StatsDividend{
-				Return: stats.Dividend.Return,
+				Return:         stats.Dividend.Return,
+				GrossReturn:    stats.Dividend.GrossReturn,
+				Tax:            stats.Dividend.Tax,
+				AfterTaxAmount: stats.Dividend.AfterTaxAmount,
 			},
 		}
+	default:
+		return Stats{}
 	}
 }
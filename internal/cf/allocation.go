@@ -0,0 +1,139 @@
+package cf
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Dimension is a dimension by which the portfolio can be broken down.
+type Dimension string
+
+const (
+	AssetClass Dimension = "asset_class"
+	Sector     Dimension = "sector"
+	Country    Dimension = "country"
+	Tag        Dimension = "tag"
+	// Security breaks down the portfolio by the ID of each stock, which is its
+	// ISIN or symbol.
+	Security Dimension = "security"
+)
+
+func ParseDimension(s string) (Dimension, error) {
+	switch d := Dimension(s); d {
+	case AssetClass, Sector, Country, Tag, Security:
+		return d, nil
+	default:
+		return "", fmt.Errorf("cf: invalid dimension %q", s)
+	}
+}
+
+const (
+	// Unknown is the allocation key of stocks without a value for a
+	// dimension.
+	Unknown = "unknown"
+	// Other is the allocation key of the part of a stock not covered by
+	// its look-through weights.
+	Other = "other"
+	// Cash is the asset class of the cash balances of depots.
+	Cash = "cash"
+)
+
+// Allocation returns the weights of stock s in dimension d. Look-through
+// weights of the stock take precedence over its single value; weights
+// summing up to less than one are completed by Other. Every tag of a stock
+// has a weight of one.
+func (s *Stock) Allocation(d Dimension) map[string]decimal.Decimal {
+	if weights := s.Weights[d]; len(weights) > 0 {
+		allocation := map[string]decimal.Decimal{}
+		total := decimal.Zero
+		for key, w := range weights {
+			allocation[key] = w
+			total = total.Add(w)
+		}
+		if rest := decimal.NewFromInt(1).Sub(total); rest.IsPositive() {
+			allocation[Other] = allocation[Other].Add(rest)
+		}
+		return allocation
+	}
+
+	var keys []string
+	switch d {
+	case AssetClass:
+		keys = []string{s.AssetClass}
+	case Sector:
+		keys = []string{s.Sector}
+	case Country:
+		keys = []string{s.Country}
+	case Tag:
+		keys = s.Tags
+	case Security:
+		keys = []string{s.ID()}
+	}
+
+	allocation := map[string]decimal.Decimal{}
+	for _, key := range keys {
+		if key == "" {
+			key = Unknown
+		}
+		allocation[key] = decimal.NewFromInt(1)
+	}
+	if len(allocation) == 0 {
+		allocation[Unknown] = decimal.NewFromInt(1)
+	}
+	return allocation
+}
+
+// AllocationEntry is the market value allocated to a key of a dimension.
+// Weight is the share of the value in the total value of the portfolio.
+type AllocationEntry struct {
+	Key    string
+	Value  decimal.Decimal
+	Weight float64
+}
+
+// CalculateAllocation breaks down the market value of the portfolio on date
+// by dimension d, from the largest to the smallest value. Cash balances are
+// included as the asset class Cash. Weights by Tag may add up to more or less
+// than one since stocks can have any number of tags.
+func CalculateAllocation(price PriceFunc, portfolio Portfolio, date time.Time, d Dimension) (decimal.Decimal, []AllocationEntry) {
+	var (
+		total  = decimal.Zero
+		values = map[string]decimal.Decimal{}
+	)
+	for s, ps := range portfolio.Stocks {
+		shares := ps.Shares()
+		if !shares.IsPositive() {
+			continue
+		}
+		value := UnitPrice(price, s, date).Mul(shares)
+		total = total.Add(value)
+		for key, w := range s.Allocation(d) {
+			values[key] = values[key].Add(value.Mul(w))
+		}
+	}
+	if cash := portfolio.CashBalance(); !cash.IsZero() {
+		total = total.Add(cash)
+		if d == AssetClass {
+			values[Cash] = values[Cash].Add(cash)
+		}
+	}
+
+	entries := make([]AllocationEntry, 0, len(values))
+	for key, value := range values {
+		entry := AllocationEntry{Key: key, Value: value}
+		if !total.IsZero() {
+			entry.Weight = Float64(value.Div(total))
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].Value.Equal(entries[j].Value) {
+			return entries[i].Value.GreaterThan(entries[j].Value)
+		}
+		return entries[i].Key < entries[j].Key
+	})
+	return total, entries
+}
@@ -0,0 +1,97 @@
+package cf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestCalculateAllocation(t *testing.T) {
+	var (
+		stock = &Stock{ISIN: "US0000000001", AssetClass: "equity", Country: "US"}
+		fund  = &Stock{
+			ISIN:       "IE0000000001",
+			AssetClass: "equity",
+			Weights: map[Dimension]map[string]decimal.Decimal{
+				Country: {
+					"US": decimal.RequireFromString("0.6"),
+					"DE": decimal.RequireFromString("0.3"),
+				},
+			},
+		}
+		depot = &Depot{Name: "comdirect"}
+	)
+	depot.Transactions = Transactions{
+		{
+			Type:   Deposit,
+			Date:   Date(2020, 1, 1),
+			Amount: decimal.RequireFromString("1500"),
+			Depot:  "comdirect",
+		},
+	}
+	stock.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2020, 1, 1),
+			Amount: decimal.RequireFromString("-1000"),
+			Shares: decimal.RequireFromString("-10"),
+			Depot:  "comdirect",
+			Stock:  stock,
+		},
+	}
+	fund.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2020, 1, 1),
+			Amount: decimal.RequireFromString("-2000"),
+			Shares: decimal.RequireFromString("-20"),
+			Stock:  fund,
+		},
+	}
+
+	transactions, stats, err := CalculateStats([]*Stock{stock, fund}, []*Depot{depot})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	price := func(s *Stock, date time.Time) decimal.Decimal {
+		return decimal.NewFromInt(100)
+	}
+
+	date := Date(2020, 12, 31)
+	portfolio := PortfolioAt(transactions, stats, date)
+
+	values := map[string]decimal.Decimal{}
+	total, entries := CalculateAllocation(price, portfolio, date, Country)
+	for _, e := range entries {
+		values[e.Key] = e.Value
+	}
+	_, byClass := CalculateAllocation(price, portfolio, date, AssetClass)
+	for _, e := range byClass {
+		values["class "+e.Key] = e.Value
+	}
+
+	testCases := map[string]struct {
+		Actual   decimal.Decimal
+		Expected string
+	}{
+		"total":       {total, "3500"},
+		"US":          {values["US"], "2200"},
+		"DE":          {values["DE"], "600"},
+		"other":       {values[Other], "200"},
+		"class cash":  {values["class "+Cash], "500"},
+		"class stock": {values["class equity"], "3000"},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if expected := decimal.RequireFromString(testCase.Expected); !testCase.Actual.Equal(expected) {
+				t.Fatalf("expected %s, got %s", expected, testCase.Actual)
+			}
+		})
+	}
+
+	if entries[0].Key != "US" {
+		t.Fatalf("expected US first, got %s", entries[0].Key)
+	}
+}
@@ -0,0 +1,183 @@
+package cf
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var isinRegexp = regexp.MustCompile(`^[A-Z]{2}[A-Z0-9]{9}[0-9]$`)
+
+// ParseBenchmark parses a benchmark given as a symbol or ISIN, optionally
+// followed by a colon and the currency of its prices, like "IWDA.AS:EUR".
+// The returned stock has no transactions. A benchmark given by ISIN has no
+// symbol until it is resolved by ResolveBenchmark.
+func ParseBenchmark(s string) (*Stock, error) {
+	id, currency := s, ""
+	if i := strings.LastIndex(s, ":"); i >= 0 {
+		id, currency = s[:i], strings.ToUpper(s[i+1:])
+	}
+	if id = strings.TrimSpace(id); id == "" {
+		return nil, fmt.Errorf("cf: invalid benchmark %q", s)
+	}
+	stock := &Stock{
+		Name:     id,
+		Currency: currency,
+	}
+	if isinRegexp.MatchString(id) {
+		stock.ISIN = id
+	} else {
+		stock.Symbol = id
+	}
+	return stock, nil
+}
+
+// ResolveBenchmark returns benchmark if it has a symbol, or a copy of it with
+// the name, symbol and kind of the stock with its ISIN otherwise. The
+// currency of the stock is used unless the benchmark has one. It returns an
+// error if there is no such stock, as prices are looked up by symbol.
+func ResolveBenchmark(benchmark *Stock, stocks []*Stock) (*Stock, error) {
+	if benchmark.Symbol != "" {
+		return benchmark, nil
+	}
+	for _, s := range stocks {
+		if s.ISIN != benchmark.ISIN || s.Symbol == "" {
+			continue
+		}
+		resolved := &Stock{}
+		*resolved = *benchmark
+		resolved.Name = s.Name
+		resolved.Symbol = s.Symbol
+		resolved.Kind = s.Kind
+		if resolved.Currency == "" {
+			resolved.Currency = s.Currency
+		}
+		return resolved, nil
+	}
+	return nil, fmt.Errorf("cf: no stock with symbol for benchmark %s", benchmark.ISIN)
+}
+
+// ReplayBenchmark returns a copy of benchmark with the cash flows of the stock
+// transactions replayed into it: every buy invests the same amount into the
+// benchmark, and every sell and dividend withdraws the same amount, as far as
+// the benchmark position allows. It returns an error if there is no benchmark
+// price on the day of a transaction.
+func ReplayBenchmark(benchmark *Stock, price PriceFunc, transactions Transactions) (*Stock, error) {
+	replayed := &Stock{}
+	*replayed = *benchmark
+	replayed.Transactions = nil
+
+	shares := decimal.Zero
+	for _, t := range transactions {
+		if t.Stock == nil || t.CashFlow().IsZero() {
+			continue
+		}
+		switch t.Type {
+		case Buy, Sell, Short, Cover, Dividend, Coupon:
+		default:
+			continue
+		}
+		p := price(benchmark, t.Date)
+		if !p.IsPositive() {
+			return nil, fmt.Errorf("cf: no price of benchmark %s on %s", benchmark.ID(), t.Date.Format("2006-01-02"))
+		}
+
+		if t.Amount.IsNegative() {
+			n := t.Amount.Neg().Div(p)
+			shares = shares.Add(n)
+			replayed.Transactions = append(replayed.Transactions, &Transaction{
+				Type:   Buy,
+				Date:   t.Date,
+				Amount: t.Amount,
+				Shares: n.Neg(),
+				Stock:  replayed,
+			})
+			continue
+		}
+
+		if !shares.IsPositive() {
+			continue
+		}
+		var (
+			amount = t.Amount
+			n      = amount.Div(p)
+		)
+		if n.GreaterThan(shares) {
+			n, amount = shares, shares.Mul(p)
+		}
+		shares = shares.Sub(n)
+		replayed.Transactions = append(replayed.Transactions, &Transaction{
+			Type:   Sell,
+			Date:   t.Date,
+			Amount: amount,
+			Shares: n,
+			Stock:  replayed,
+		})
+	}
+	return replayed, nil
+}
+
+// BenchmarkComparison compares a portfolio with a benchmark into which the
+// cash flows of the portfolio have been replayed. Alpha is the difference of
+// the IRRs and TrackingDifference the difference of the time-weighted returns
+// of the portfolio and the benchmark.
+type BenchmarkComparison struct {
+	Benchmark          *Stock
+	Value              decimal.Decimal
+	Performances       Performances
+	Alpha              float64
+	TrackingDifference float64
+	Periods            []BenchmarkPeriod
+}
+
+type BenchmarkPeriod struct {
+	PeriodPerformance
+	Alpha              float64
+	TrackingDifference float64
+}
+
+// CompareBenchmark replays transactions into benchmark and compares the
+// performances of the portfolio with those of the benchmark as of date and
+// over the periods of the portfolio's period performances.
+func CompareBenchmark(ctx context.Context, price PriceFunc, conv *Converter, benchmark *Stock, transactions Transactions, performances Performances, periods []PeriodPerformance, date time.Time) (BenchmarkComparison, error) {
+	replayed, err := ReplayBenchmark(benchmark, price, transactions)
+	if err != nil {
+		return BenchmarkComparison{}, err
+	}
+	benchmarkTransactions, stats, err := CalculateStats([]*Stock{replayed}, nil)
+	if err != nil {
+		return BenchmarkComparison{}, err
+	}
+
+	benchmarkPeriods := make([]Period, len(periods))
+	for i, p := range periods {
+		benchmarkPeriods[i] = p.Period
+	}
+
+	var (
+		portfolio             = PortfolioAt(benchmarkTransactions, stats, date)
+		benchmarkPerformances = CalculatePerformances(ctx, price, conv, benchmarkTransactions, stats, date)
+		periodPerformances    = CalculatePeriodPerformances(ctx, price, conv, benchmarkTransactions, stats, benchmarkPeriods)
+	)
+
+	comparison := BenchmarkComparison{
+		Benchmark:          benchmark,
+		Value:              portfolioValue(price, portfolio, date),
+		Performances:       benchmarkPerformances,
+		Alpha:              performances.IRR - benchmarkPerformances.IRR,
+		TrackingDifference: performances.Overall.TWR - benchmarkPerformances.Overall.TWR,
+		Periods:            make([]BenchmarkPeriod, len(periods)),
+	}
+	for i, p := range periodPerformances {
+		comparison.Periods[i] = BenchmarkPeriod{
+			PeriodPerformance:  p,
+			Alpha:              periods[i].IRR - p.IRR,
+			TrackingDifference: periods[i].Performance.TWR - p.Performance.TWR,
+		}
+	}
+	return comparison, nil
+}
@@ -0,0 +1,160 @@
+package cf
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestParseBenchmark(t *testing.T) {
+	testCases := map[string]struct {
+		Symbol   string
+		ISIN     string
+		Currency string
+	}{
+		"IWDA.AS":          {"IWDA.AS", "", ""},
+		"IWDA.AS:eur":      {"IWDA.AS", "", "EUR"},
+		"IE00B4L5Y983":     {"", "IE00B4L5Y983", ""},
+		"IE00B4L5Y983:USD": {"", "IE00B4L5Y983", "USD"},
+	}
+	for s, testCase := range testCases {
+		t.Run(s, func(t *testing.T) {
+			stock, err := ParseBenchmark(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stock.Symbol != testCase.Symbol || stock.ISIN != testCase.ISIN || stock.Currency != testCase.Currency {
+				t.Fatalf("unexpected benchmark %+v", stock)
+			}
+		})
+	}
+
+	if _, err := ParseBenchmark(":EUR"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestResolveBenchmark(t *testing.T) {
+	stocks := []*Stock{
+		{Name: "iShares Core MSCI World", Symbol: "IWDA.AS", ISIN: "IE00B4L5Y983", Currency: "EUR"},
+	}
+
+	benchmark, err := ParseBenchmark("IE00B4L5Y983")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := ResolveBenchmark(benchmark, stocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.Symbol != "IWDA.AS" || resolved.Currency != "EUR" || resolved.ID() != benchmark.ID() {
+		t.Fatalf("unexpected benchmark %+v", resolved)
+	}
+	if benchmark.Symbol != "" {
+		t.Fatal("expected benchmark to be unchanged")
+	}
+
+	unknown, err := ParseBenchmark("US0378331005")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ResolveBenchmark(unknown, stocks); err == nil {
+		t.Fatal("expected error for unknown ISIN")
+	}
+}
+
+func TestCompareBenchmark(t *testing.T) {
+	var (
+		stock     = &Stock{ISIN: "US88160R1014"}
+		benchmark = &Stock{Symbol: "IWDA.AS"}
+	)
+	stock.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2020, 1, 1),
+			Amount: decimal.RequireFromString("-1000"),
+			Shares: decimal.RequireFromString("-10"),
+			Stock:  stock,
+		},
+		{
+			Type:   Sell,
+			Date:   Date(2020, 7, 1),
+			Amount: decimal.RequireFromString("600"),
+			Shares: decimal.RequireFromString("5"),
+			Stock:  stock,
+		},
+	}
+
+	price := func(s *Stock, date time.Time) decimal.Decimal {
+		switch {
+		case s == stock && date.Before(Date(2020, 7, 1)):
+			return decimal.NewFromInt(100)
+		case s == stock:
+			return decimal.NewFromInt(120)
+		case date.Before(Date(2020, 7, 1)):
+			return decimal.NewFromInt(50)
+		default:
+			return decimal.NewFromInt(55)
+		}
+	}
+
+	replayed, err := ReplayBenchmark(benchmark, price, stock.Transactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(replayed.Transactions); n != 2 {
+		t.Fatalf("expected 2 replayed transactions, got %d", n)
+	}
+	if shares := replayed.Transactions[0].Shares; !shares.Equal(decimal.NewFromInt(-20)) {
+		t.Fatalf("expected -20 shares bought, got %s", shares)
+	}
+
+	unpriced := func(s *Stock, date time.Time) decimal.Decimal {
+		if s == benchmark && date.Before(Date(2020, 7, 1)) {
+			return decimal.Zero
+		}
+		return price(s, date)
+	}
+	if _, err := ReplayBenchmark(benchmark, unpriced, stock.Transactions); err == nil {
+		t.Fatal("expected error for missing benchmark price")
+	}
+
+	transactions, stats, err := CalculateStats([]*Stock{stock}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		ctx          = context.Background()
+		date         = Date(2020, 12, 31)
+		performances = CalculatePerformances(ctx, price, nil, transactions, stats, date)
+		periods      = CalculatePeriodPerformances(ctx, price, nil, transactions, stats, []Period{{Name: "2020", Begin: Date(2020, 1, 1), End: date}})
+	)
+
+	comparison, err := CompareBenchmark(ctx, price, nil, benchmark, transactions, performances, periods, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 20 shares bought for 1000, 600/55 shares sold, leaving 20 - 600/55 shares.
+	expectedValue := decimal.NewFromInt(20).Sub(decimal.NewFromInt(600).Div(decimal.NewFromInt(55))).Mul(decimal.NewFromInt(55))
+	if !comparison.Value.Round(8).Equal(expectedValue.Round(8)) {
+		t.Fatalf("expected value %s, got %s", expectedValue, comparison.Value)
+	}
+
+	// The stock returned 20%, the benchmark 10%.
+	if math.Abs(comparison.TrackingDifference-0.1) > 1e-9 {
+		t.Fatalf("expected tracking difference 0.1, got %f", comparison.TrackingDifference)
+	}
+	if comparison.Alpha <= 0 {
+		t.Fatalf("expected positive alpha, got %f", comparison.Alpha)
+	}
+	if len(comparison.Periods) != 1 {
+		t.Fatalf("expected 1 period, got %d", len(comparison.Periods))
+	}
+	if p := comparison.Periods[0]; math.Abs(p.TrackingDifference-comparison.TrackingDifference) > 1e-9 {
+		t.Fatalf("expected period tracking difference %f, got %f", comparison.TrackingDifference, p.TrackingDifference)
+	}
+}
@@ -0,0 +1,158 @@
+package cf
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/thcyron/cashflow/internal/xirr"
+)
+
+var hundred = decimal.NewFromInt(100)
+
+// Multiplier returns the value of one share or unit of the stock at a price
+// of one. The price of a bond is a percentage of its nominal value, and the
+// price of an option or warrant is per share of its underlying.
+func (s *Stock) Multiplier() decimal.Decimal {
+	switch s.Kind {
+	case BondKind:
+		return s.nominal().Div(hundred)
+	case OptionKind, WarrantKind:
+		if !s.ContractSize.IsZero() {
+			return s.ContractSize
+		}
+	}
+	return decimal.NewFromInt(1)
+}
+
+// nominal returns the nominal value of one unit of a bond. Units of bonds
+// without Nominal are their nominal amounts.
+func (s *Stock) nominal() decimal.Decimal {
+	if s.Nominal.IsZero() {
+		return decimal.NewFromInt(1)
+	}
+	return s.Nominal
+}
+
+// UnitPrice returns the value of one share or unit of stock s on date, which
+// is its price times its multiplier.
+func UnitPrice(price PriceFunc, s *Stock, date time.Time) decimal.Decimal {
+	return price(s, date).Mul(s.Multiplier())
+}
+
+// Redeemed returns a PriceFunc which returns the prices of price, except for
+// bonds on or after Maturity. They are redeemed at their nominal value and
+// are priced at 100 percent from then on, until the redemption is recorded as
+// a sell. Converter.PriceFunc must wrap it to convert the redemption price.
+func Redeemed(price PriceFunc) PriceFunc {
+	return func(s *Stock, date time.Time) decimal.Decimal {
+		if s.Kind == BondKind && !s.Maturity.IsZero() && !date.Before(s.Maturity) {
+			return hundred
+		}
+		return price(s, date)
+	}
+}
+
+// CouponDates returns the coupon dates of the bond after after up to and
+// including until. Coupons are paid Frequency times a year on the day of
+// Maturity, or on the last day of shorter months, and the last coupon is
+// paid on Maturity.
+func (s *Stock) CouponDates(after, until time.Time) []time.Time {
+	if s.Kind != BondKind || s.Frequency <= 0 || s.Maturity.IsZero() {
+		return nil
+	}
+	if until.After(s.Maturity) {
+		until = s.Maturity
+	}
+
+	var dates []time.Time
+	for i := 0; ; i++ {
+		date := s.couponDate(i)
+		if !date.After(after) {
+			break
+		}
+		if !date.After(until) {
+			dates = append([]time.Time{date}, dates...)
+		}
+	}
+	return dates
+}
+
+// couponDate returns the date of the coupon paid i periods before maturity.
+func (s *Stock) couponDate(i int) time.Time {
+	var (
+		months = 12 / s.Frequency
+		first  = time.Date(s.Maturity.Year(), s.Maturity.Month()-time.Month(i*months), 1, 0, 0, 0, 0, time.UTC)
+		day    = s.Maturity.Day()
+	)
+	if last := first.AddDate(0, 1, -1).Day(); last < day {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// CouponAmount returns the coupon paid on each coupon date for shares units
+// of the bond.
+func (s *Stock) CouponAmount(shares decimal.Decimal) decimal.Decimal {
+	if s.Frequency <= 0 {
+		return decimal.Zero
+	}
+	return shares.Mul(s.nominal()).Mul(s.Coupon).Div(decimal.NewFromInt(int64(s.Frequency)))
+}
+
+// AccruedInterest returns the interest accrued on shares units of the bond
+// since the last coupon date before date, counting actual days.
+func (s *Stock) AccruedInterest(shares decimal.Decimal, date time.Time) decimal.Decimal {
+	if s.Kind != BondKind || s.Frequency <= 0 || s.Maturity.IsZero() || !date.Before(s.Maturity) {
+		return decimal.Zero
+	}
+	i := 0
+	for s.couponDate(i + 1).After(date) {
+		i++
+	}
+	var (
+		last    = s.couponDate(i + 1)
+		next    = s.couponDate(i)
+		elapsed = decimal.NewFromInt(int64(days(last, date)))
+		period  = decimal.NewFromInt(int64(days(last, next)))
+	)
+	return s.CouponAmount(shares).Mul(elapsed).Div(period)
+}
+
+// YieldToMaturity returns the annualized yield of buying the bond at price on
+// date, paying the accrued interest, and holding it until it is redeemed at
+// its nominal value on Maturity.
+func (s *Stock) YieldToMaturity(price decimal.Decimal, date time.Time) (float64, error) {
+	if s.Kind != BondKind || s.Maturity.IsZero() {
+		return 0, errors.New("cf: not a bond with maturity")
+	}
+	if !date.Before(s.Maturity) {
+		return 0, errors.New("cf: bond has matured")
+	}
+	if !price.IsPositive() {
+		return 0, errors.New("cf: no price")
+	}
+
+	one := decimal.NewFromInt(1)
+	values := []xirr.Value{{
+		Amount: -Float64(price.Mul(s.Multiplier()).Add(s.AccruedInterest(one, date))),
+		Date:   date,
+	}}
+	for _, d := range s.CouponDates(date, s.Maturity) {
+		values = append(values, xirr.Value{
+			Amount: Float64(s.CouponAmount(one)),
+			Date:   d,
+		})
+	}
+	values = append(values, xirr.Value{
+		Amount: Float64(s.nominal()),
+		Date:   s.Maturity,
+	})
+	ytm := xirr.XIRR(values, Float64(s.Coupon))
+	if math.IsNaN(ytm) {
+		return 0, errors.New("cf: yield to maturity does not converge")
+	}
+	return ytm, nil
+}
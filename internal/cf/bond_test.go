@@ -0,0 +1,125 @@
+package cf
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/shopspring/decimal"
+)
+
+func testBond() *Stock {
+	return &Stock{
+		ISIN:      "DE0001102382",
+		Kind:      BondKind,
+		Nominal:   decimal.RequireFromString("1000"),
+		Coupon:    decimal.RequireFromString("0.04"),
+		Frequency: 2,
+		Maturity:  Date(2025, 6, 15),
+	}
+}
+
+func TestBond(t *testing.T) {
+	bond := testBond()
+	bond.Transactions = Transactions{
+		{
+			Type:            Buy,
+			Date:            Date(2020, 3, 15),
+			Amount:          decimal.RequireFromString("-9899.45"),
+			Shares:          decimal.RequireFromString("-10"),
+			AccruedInterest: decimal.RequireFromString("-99.45"),
+			Stock:           bond,
+		},
+		{
+			Type:   Coupon,
+			Date:   Date(2020, 6, 15),
+			Amount: decimal.RequireFromString("200"),
+			Stock:  bond,
+		},
+		{
+			Type:            Sell,
+			Date:            Date(2021, 1, 15),
+			Amount:          decimal.RequireFromString("5066.94"),
+			Shares:          decimal.RequireFromString("5"),
+			AccruedInterest: decimal.RequireFromString("16.94"),
+			Stock:           bond,
+		},
+	}
+
+	_, stats, err := CalculateStats([]*Stock{bond}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		buy   = stats[bond.Transactions[0]]
+		sell  = stats[bond.Transactions[2]]
+		price = func(stock *Stock, date time.Time) decimal.Decimal {
+			return decimal.RequireFromString("100")
+		}
+	)
+
+	testCases := map[string]struct {
+		Actual   decimal.Decimal
+		Expected string
+	}{
+		"multiplier":             {bond.Multiplier(), "10"},
+		"accrued interest":       {bond.AccruedInterest(decimal.NewFromInt(10), Date(2020, 3, 15)).Round(2), "99.45"},
+		"accrued on coupon date": {bond.AccruedInterest(decimal.NewFromInt(10), Date(2020, 6, 15)), "0"},
+		"invested":               {buy.Portfolio.Invested(), "9800"},
+		"value":                  {buy.Portfolio.Value(price, Date(2020, 3, 15)), "10000"},
+		"value at maturity":      {buy.Portfolio.Value(Redeemed(zeroPriceFunc), Date(2025, 6, 15)), "10000"},
+		"price per share":        {buy.Buy.PricePerShare, "980"},
+		"profit":                 {sell.Sell.Profit, "150"},
+		"income":                 {sell.Portfolio.Dividends(), "117.49"},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if expected := decimal.RequireFromString(testCase.Expected); !testCase.Actual.Equal(expected) {
+				t.Fatalf("expected %s, got %s", expected, testCase.Actual)
+			}
+		})
+	}
+}
+
+func TestCouponDates(t *testing.T) {
+	expected := []time.Time{
+		Date(2020, 6, 15),
+		Date(2020, 12, 15),
+		Date(2021, 6, 15),
+		Date(2021, 12, 15),
+	}
+	if dates := testBond().CouponDates(Date(2020, 1, 1), Date(2021, 12, 31)); !cmp.Equal(expected, dates) {
+		t.Fatal(cmp.Diff(expected, dates))
+	}
+}
+
+func TestYieldToMaturity(t *testing.T) {
+	bond := testBond()
+
+	testCases := map[string]struct {
+		Price    string
+		Date     time.Time
+		Expected float64
+	}{
+		"par":      {Price: "100", Date: Date(2020, 6, 15), Expected: 0.0404},
+		"discount": {Price: "95", Date: Date(2020, 6, 15), Expected: 0.0520},
+		"premium":  {Price: "105", Date: Date(2020, 6, 15), Expected: 0.0295},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ytm, err := bond.YieldToMaturity(decimal.RequireFromString(testCase.Price), testCase.Date)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(ytm-testCase.Expected) > 0.001 {
+				t.Fatalf("expected %f, got %f", testCase.Expected, ytm)
+			}
+		})
+	}
+
+	if _, err := bond.YieldToMaturity(decimal.RequireFromString("100"), Date(2025, 6, 15)); err == nil {
+		t.Fatal("expected error for matured bond")
+	}
+}
@@ -0,0 +1,139 @@
+package cf
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// CorporateAction moves or allocates the batches of the stock with the ID
+// t.Source to stock s, keeping their dates and cost basis:
+//
+// A merger exchanges all shares of the source stock for shares of s at
+// t.Ratio, which is 1:1 for a change of ISIN. A spin-off allots shares of s at
+// t.Ratio of the shares of the source stock, which are kept, and carves out
+// t.CostShare of their cost basis.
+//
+// Nothing happens if the source stock is not in the portfolio.
+func (p Portfolio) CorporateAction(s *Stock, t *Transaction) {
+	var source *PortfolioStock
+	for stock, ps := range p.Stocks {
+		if stock.ID() == t.Source && stock != s {
+			source = ps
+			break
+		}
+	}
+	if source == nil || len(source.Batches) == 0 {
+		return
+	}
+
+	ps, ok := p.Stocks[s]
+	if !ok {
+		ps = &PortfolioStock{}
+		p.Stocks[s] = ps
+	}
+
+	for i, b := range source.Batches {
+		nb := b
+		nb.Shares = t.Ratio.Shares(b.Shares)
+		nb.Transactions = append(b.Transactions[:0:0], b.Transactions...)
+		nb.Transactions = append(nb.Transactions, t)
+
+		if t.Type == SpinOff {
+			rest := decimal.NewFromInt(1).Sub(t.CostShare)
+			nb.PricePerShare = b.Invested().Mul(t.CostShare).Div(nb.Shares)
+			nb.Fees = b.Fees.Mul(t.CostShare)
+			nb.Taxes = b.Taxes.Mul(t.CostShare)
+			source.Batches[i].PricePerShare = b.PricePerShare.Mul(rest)
+			source.Batches[i].Fees = b.Fees.Mul(rest)
+			source.Batches[i].Taxes = b.Taxes.Mul(rest)
+		} else {
+			nb.PricePerShare = t.Ratio.Price(b.PricePerShare)
+		}
+		ps.Batches = append(ps.Batches, nb)
+	}
+	if t.Type == Merger {
+		source.Batches = nil
+	}
+}
+
+// Related returns s and all stocks linked to it by mergers and spin-offs,
+// directly or indirectly, in the order of stocks.
+func Related(stocks []*Stock, s *Stock) []*Stock {
+	links := map[string][]string{}
+	for _, stock := range stocks {
+		for _, t := range stock.Transactions {
+			if t.Type == Merger || t.Type == SpinOff {
+				links[stock.ID()] = append(links[stock.ID()], t.Source)
+				links[t.Source] = append(links[t.Source], stock.ID())
+			}
+		}
+	}
+
+	var (
+		related = map[string]bool{s.ID(): true}
+		queue   = []string{s.ID()}
+	)
+	for len(queue) > 0 {
+		isin := queue[0]
+		queue = queue[1:]
+		for _, linked := range links[isin] {
+			if !related[linked] {
+				related[linked] = true
+				queue = append(queue, linked)
+			}
+		}
+	}
+
+	var result []*Stock
+	for _, stock := range stocks {
+		if stock == s || related[stock.ID()] {
+			result = append(result, stock)
+		}
+	}
+	return result
+}
+
+// OwnStats returns the transactions and stats of stock s alone out of the
+// transactions and stats of s and its related stocks, so that the performance
+// of s includes neither the cash flows nor the value of the related stocks.
+// Corporate actions moving cost basis into s count as buys and those moving
+// cost basis out of s as sells at that cost basis.
+func OwnStats(s *Stock, transactions Transactions, stats map[*Transaction]Stats) (Transactions, map[*Transaction]Stats) {
+	own := map[*Transaction]bool{}
+	for _, t := range s.Transactions {
+		own[t] = true
+	}
+
+	var (
+		ownTransactions Transactions
+		ownStats        = map[*Transaction]Stats{}
+		invested        = decimal.Zero
+	)
+	for _, t := range transactions {
+		st := stats[t]
+		ps := st.Portfolio.Stocks[s]
+		before := invested
+		if ps != nil {
+			invested = ps.Invested()
+		} else {
+			invested = decimal.Zero
+		}
+
+		corporateAction := t.Type == Merger || t.Type == SpinOff
+		switch {
+		case corporateAction && (own[t] || t.Source == s.ID()):
+			t = t.Clone()
+			t.Amount = before.Sub(invested)
+		case !own[t]:
+			continue
+		}
+
+		st.Transaction = t
+		st.Portfolio = NewPortfolio(nil)
+		if ps != nil {
+			st.Portfolio.Stocks[s] = ps
+		}
+		ownTransactions = append(ownTransactions, t)
+		ownStats[t] = st
+	}
+	return ownTransactions, ownStats
+}
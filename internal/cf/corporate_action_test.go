@@ -0,0 +1,160 @@
+package cf
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestCorporateActions(t *testing.T) {
+	var (
+		old    = &Stock{ISIN: "DE0000000001"}
+		merged = &Stock{ISIN: "DE0000000002"}
+		parent = &Stock{ISIN: "US0000000001"}
+		child  = &Stock{ISIN: "US0000000002"}
+	)
+	old.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2019, 1, 1),
+			Amount: decimal.RequireFromString("-1000"),
+			Shares: decimal.RequireFromString("-10"),
+			Stock:  old,
+		},
+	}
+	merged.Transactions = Transactions{
+		{
+			Type:   Merger,
+			Date:   Date(2020, 1, 1),
+			Ratio:  Ratio{New: decimal.NewFromInt(1), Old: decimal.NewFromInt(2)},
+			Source: old.ISIN,
+			Stock:  merged,
+		},
+		{
+			Type:   Sell,
+			Date:   Date(2021, 6, 1),
+			Amount: decimal.RequireFromString("1500"),
+			Shares: decimal.RequireFromString("5"),
+			Stock:  merged,
+		},
+	}
+	parent.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2019, 1, 1),
+			Amount: decimal.RequireFromString("-1000"),
+			Shares: decimal.RequireFromString("-10"),
+			Stock:  parent,
+		},
+	}
+	child.Transactions = Transactions{
+		{
+			Type:      SpinOff,
+			Date:      Date(2020, 1, 1),
+			Ratio:     Ratio{New: decimal.NewFromInt(1), Old: decimal.NewFromInt(5)},
+			Source:    parent.ISIN,
+			CostShare: decimal.RequireFromString("0.2"),
+			Stock:     child,
+		},
+	}
+
+	transactions, stats, err := CalculateStats([]*Stock{old, merged, parent, child}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		spunOff = stats[child.Transactions[0]].Portfolio
+		sell    = stats[merged.Transactions[1]]
+		end     = stats[transactions[len(transactions)-1]].Portfolio
+	)
+
+	testCases := map[string]struct {
+		Actual   decimal.Decimal
+		Expected string
+	}{
+		"old shares":      {end.Stocks[old].Shares(), "0"},
+		"sell profit":     {sell.Sell.Profit, "500"},
+		"merged shares":   {end.Stocks[merged].Shares(), "0"},
+		"parent shares":   {spunOff.Stocks[parent].Shares(), "10"},
+		"parent invested": {spunOff.Stocks[parent].Invested(), "800"},
+		"child shares":    {spunOff.Stocks[child].Shares(), "2"},
+		"child invested":  {spunOff.Stocks[child].Invested(), "200"},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if expected := decimal.RequireFromString(testCase.Expected); !testCase.Actual.Equal(expected) {
+				t.Fatalf("expected %s, got %s", expected, testCase.Actual)
+			}
+		})
+	}
+
+	if lot := sell.Sell.Lots[0]; !lot.Acquired.Equal(Date(2019, 1, 1)) || !lot.LongTerm() {
+		t.Fatalf("expected long-term lot acquired on 2019-01-01, got %s", lot.Acquired)
+	}
+	if b := spunOff.Stocks[child].Batches[0]; !b.Date.Equal(Date(2019, 1, 1)) {
+		t.Fatalf("expected spun-off batch acquired on 2019-01-01, got %s", b.Date)
+	}
+	if related := Related([]*Stock{old, merged, parent, child}, merged); len(related) != 2 || related[0] != old {
+		t.Fatalf("expected old and merged stock to be related, got %d stocks", len(related))
+	}
+
+	// The stats of the merged stock alone start with the cost basis moved
+	// into it and do not include the old stock.
+	ownTransactions, ownStats := OwnStats(merged, transactions, stats)
+	if len(ownTransactions) != 2 {
+		t.Fatalf("expected 2 own transactions of merged stock, got %d", len(ownTransactions))
+	}
+	if amount := ownTransactions[0].Amount; !amount.Equal(decimal.NewFromInt(-1000)) {
+		t.Fatalf("expected merger to move in 1000 cost basis, got %s", amount)
+	}
+	if n := len(ownStats[ownTransactions[0]].Portfolio.Stocks); n != 1 {
+		t.Fatalf("expected only the merged stock in its portfolio, got %d stocks", n)
+	}
+	parentTransactions, _ := OwnStats(parent, transactions, stats)
+	if len(parentTransactions) != 2 {
+		t.Fatalf("expected 2 own transactions of parent stock, got %d", len(parentTransactions))
+	}
+	if amount := parentTransactions[1].Amount; !amount.Equal(decimal.NewFromInt(200)) {
+		t.Fatalf("expected spin-off to move out 200 cost basis, got %s", amount)
+	}
+
+	var (
+		btc = &Stock{Symbol: "BTC", Kind: CryptoKind}
+		eth = &Stock{Symbol: "ETH", Kind: CryptoKind}
+	)
+	if related := Related([]*Stock{btc, eth}, eth); len(related) != 1 || related[0] != eth {
+		t.Fatalf("expected stocks without ISIN to be unrelated, got %d stocks", len(related))
+	}
+
+	// Stocks without an ISIN are the source of corporate actions by symbol.
+	var (
+		legacy = &Stock{Symbol: "LGCY"}
+		swap   = &Stock{Symbol: "SWAP"}
+	)
+	legacy.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2019, 1, 1),
+			Amount: decimal.RequireFromString("-1000"),
+			Shares: decimal.RequireFromString("-10"),
+			Stock:  legacy,
+		},
+	}
+	swap.Transactions = Transactions{
+		{
+			Type:   Merger,
+			Date:   Date(2020, 1, 1),
+			Ratio:  Ratio{New: decimal.NewFromInt(1), Old: decimal.NewFromInt(1)},
+			Source: legacy.ID(),
+			Stock:  swap,
+		},
+	}
+	transactions, stats, err = CalculateStats([]*Stock{legacy, swap}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shares := stats[transactions[len(transactions)-1]].Portfolio.Stocks[swap].Shares(); !shares.Equal(decimal.NewFromInt(10)) {
+		t.Fatalf("expected 10 shares of swapped stock, got %s", shares)
+	}
+}
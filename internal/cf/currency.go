@@ -0,0 +1,171 @@
+package cf
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// RateFunc returns the exchange rate for converting one unit of currency from
+// into currency to on the given date.
+type RateFunc func(from, to string, date time.Time) decimal.Decimal
+
+// RateProvider provides exchange rates. History returns the rates since the
+// given date, or of a default period of the provider if since is zero.
+type RateProvider interface {
+	History(ctx context.Context, from, to string, since time.Time) ([]Price, error)
+	Current(ctx context.Context, from, to string) (decimal.Decimal, error)
+}
+
+// Converter converts amounts into a base currency. Amounts without a currency
+// are assumed to be in the base currency already. A nil Converter does not
+// convert at all.
+type Converter struct {
+	Base string
+	Rate RateFunc
+}
+
+func NewConverter(base string, rate RateFunc) *Converter {
+	return &Converter{
+		Base: base,
+		Rate: rate,
+	}
+}
+
+// Convert converts amount from currency into the base currency using the
+// exchange rate on the given date. It returns an error if there is no rate for
+// a non-zero amount.
+func (c *Converter) Convert(amount decimal.Decimal, currency string, date time.Time) (decimal.Decimal, error) {
+	if c.isBase(currency) || amount.IsZero() {
+		return amount, nil
+	}
+	rate := c.Rate(currency, c.Base, date)
+	if !rate.IsPositive() {
+		return decimal.Zero, fmt.Errorf("cf: no exchange rate for %s/%s on %s", currency, c.Base, date.Format("2006-01-02"))
+	}
+	return amount.Mul(rate), nil
+}
+
+// PriceFunc returns a PriceFunc which returns the prices of price in the base
+// currency. Prices without an exchange rate are zero, which means there is no
+// price.
+func (c *Converter) PriceFunc(price PriceFunc) PriceFunc {
+	if c == nil {
+		return price
+	}
+	return func(stock *Stock, date time.Time) decimal.Decimal {
+		converted, err := c.Convert(price(stock, date), stock.Currency, date)
+		if err != nil {
+			return decimal.Zero
+		}
+		return converted
+	}
+}
+
+// Stocks returns clones of stocks with all transaction amounts converted into
+// the base currency using the exchange rate on the day of the transaction. It
+// returns an error if a rate is missing.
+func (c *Converter) Stocks(stocks []*Stock) ([]*Stock, error) {
+	if c == nil {
+		return stocks, nil
+	}
+	converted := make([]*Stock, len(stocks))
+	for i, stock := range stocks {
+		s := stock.Clone()
+		for _, t := range s.Transactions {
+			currency := t.Currency
+			if currency == "" {
+				currency = s.Currency
+			}
+			for _, amount := range []*decimal.Decimal{&t.Amount, &t.Fees, &t.Taxes, &t.AccruedInterest} {
+				v, err := c.Convert(*amount, currency, t.Date)
+				if err != nil {
+					return nil, err
+				}
+				*amount = v
+			}
+			t.Currency = c.Base
+		}
+		converted[i] = s
+	}
+	return converted, nil
+}
+
+// Depots returns clones of depots with all cash transaction amounts converted
+// into the base currency using the exchange rate on the day of the
+// transaction. It returns an error if a rate is missing.
+func (c *Converter) Depots(depots []*Depot) ([]*Depot, error) {
+	if c == nil {
+		return depots, nil
+	}
+	converted := make([]*Depot, len(depots))
+	for i, depot := range depots {
+		d := depot.Clone()
+		for _, t := range d.Transactions {
+			currency := t.Currency
+			if currency == "" {
+				currency = d.Currency
+			}
+			amount, err := c.Convert(t.Amount, currency, t.Date)
+			if err != nil {
+				return nil, err
+			}
+			t.Amount = amount
+			t.Currency = c.Base
+		}
+		converted[i] = d
+	}
+	return converted, nil
+}
+
+// currencyEffect returns the part of the change in the base currency value of
+// a position in stock between begin and end caused by the exchange rate.
+// value is the value of the position on end in the base currency.
+func (c *Converter) currencyEffect(stock *Stock, value decimal.Decimal, begin, end time.Time) decimal.Decimal {
+	if c.isBase(stock.Currency) {
+		return decimal.Zero
+	}
+	var (
+		beginRate = c.Rate(stock.Currency, c.Base, begin)
+		endRate   = c.Rate(stock.Currency, c.Base, end)
+	)
+	if beginRate.IsZero() || endRate.IsZero() {
+		return decimal.Zero
+	}
+	return value.Sub(value.Mul(beginRate).Div(endRate))
+}
+
+func (c *Converter) isBase(currency string) bool {
+	return c == nil || currency == "" || currency == c.Base
+}
+
+// Currencies returns all currencies used by stocks, depots, their
+// transactions and savings plans.
+func Currencies(stocks []*Stock, depots []*Depot) []string {
+	seen := map[string]bool{}
+	for _, s := range stocks {
+		seen[s.Currency] = true
+		for _, t := range s.Transactions {
+			seen[t.Currency] = true
+		}
+		for _, p := range s.SavingsPlans {
+			seen[p.Currency] = true
+		}
+	}
+	for _, d := range depots {
+		seen[d.Currency] = true
+		for _, t := range d.Transactions {
+			seen[t.Currency] = true
+		}
+	}
+	delete(seen, "")
+	currencies := make([]string, 0, len(seen))
+	for c := range seen {
+		currencies = append(currencies, c)
+	}
+	sort.Strings(currencies)
+	return currencies
+}
@@ -0,0 +1,50 @@
+package cf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestConverterMissingRate(t *testing.T) {
+	stock := &Stock{ISIN: "US88160R1014", Currency: "USD"}
+	stock.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2017, 3, 1),
+			Amount: decimal.RequireFromString("-2500"),
+			Shares: decimal.RequireFromString("-10"),
+			Stock:  stock,
+
+			AccruedInterest: decimal.RequireFromString("-100"),
+		},
+	}
+
+	conv := NewConverter("EUR", func(from, to string, date time.Time) decimal.Decimal {
+		if date.Before(Date(2020, 1, 1)) {
+			return decimal.Zero
+		}
+		return decimal.RequireFromString("0.9")
+	})
+	if _, err := conv.Stocks([]*Stock{stock}); err == nil {
+		t.Fatal("expected error for missing rate")
+	}
+
+	stock.Transactions[0].Date = Date(2020, 3, 1)
+	stocks, err := conv.Stocks([]*Stock{stock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amount := stocks[0].Transactions[0].Amount; !amount.Equal(decimal.RequireFromString("-2250")) {
+		t.Fatalf("unexpected amount %s", amount)
+	}
+	if interest := stocks[0].Transactions[0].AccruedInterest; !interest.Equal(decimal.RequireFromString("-90")) {
+		t.Fatalf("unexpected accrued interest %s", interest)
+	}
+
+	bond := &Stock{ISIN: "US912828YK04", Currency: "USD", Kind: BondKind, Nominal: decimal.NewFromInt(1000), Maturity: Date(2025, 6, 15)}
+	if p := UnitPrice(conv.PriceFunc(Redeemed(zeroPriceFunc)), bond, Date(2025, 6, 15)); !p.Equal(decimal.NewFromInt(900)) {
+		t.Fatalf("expected redemption at the converted nominal value, got %s", p)
+	}
+}
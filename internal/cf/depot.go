@@ -0,0 +1,25 @@
+package cf
+
+// Depot is a securities account. A depot with a Depot record has a cash
+// account whose balance is changed by its own cash transactions (deposits,
+// withdrawals, interest and fees) and by the buys, sells and dividends of
+// stocks in the depot. Depots without a record have no cash account.
+type Depot struct {
+	Name      string
+	Currency  string
+	LotMethod LotMethod
+
+	// Tax estimates the taxes on the income realized in the depot. It may
+	// be nil.
+	Tax TaxEngine
+
+	// Transactions are the cash transactions of the depot.
+	Transactions Transactions
+}
+
+func (d *Depot) Clone() *Depot {
+	cloned := &Depot{}
+	*cloned = *d
+	cloned.Transactions = d.Transactions.Clone()
+	return cloned
+}
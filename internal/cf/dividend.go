@@ -0,0 +1,174 @@
+package cf
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// DividendGroup is the sum of the dividends received in a year, month, stock
+// or depot. Stock is only set when grouping by stock.
+type DividendGroup struct {
+	Key         string
+	Stock       *Stock
+	Amount      decimal.Decimal
+	GrossAmount decimal.Decimal
+}
+
+// DividendYield is the dividend yield of a stock over the twelve months
+// before the report date. YieldOnCost is based on the invested amount,
+// CurrentYield on the current value.
+type DividendYield struct {
+	Stock        *Stock
+	Shares       decimal.Decimal
+	Dividends    decimal.Decimal
+	YieldOnCost  float64
+	CurrentYield float64
+}
+
+// DividendForecast is an expected dividend payment.
+type DividendForecast struct {
+	Date   time.Time
+	Stock  *Stock
+	Amount decimal.Decimal
+}
+
+type DividendReport struct {
+	Amount      decimal.Decimal
+	GrossAmount decimal.Decimal
+	ByYear      []DividendGroup
+	ByMonth     []DividendGroup
+	ByStock     []DividendGroup
+	ByDepot     []DividendGroup
+	Yields      []DividendYield
+	Forecast    []DividendForecast
+}
+
+// ForecastAmount returns the sum of all forecast dividends.
+func (r DividendReport) ForecastAmount() decimal.Decimal {
+	amount := decimal.Zero
+	for _, f := range r.Forecast {
+		amount = amount.Add(f.Amount)
+	}
+	return amount
+}
+
+// CalculateDividendReport groups the dividends received between begin and
+// end. Yields and the forecast for the twelve months after end are based on
+// the dividends received in the twelve months up to end: each payment is
+// expected to recur a year later with the same amount per share applied to
+// the shares held on end.
+func CalculateDividendReport(price PriceFunc, transactions Transactions, stats map[*Transaction]Stats, begin, end time.Time) DividendReport {
+	var (
+		report  = DividendReport{Amount: decimal.Zero, GrossAmount: decimal.Zero}
+		byYear  = dividendGroups{}
+		byMonth = dividendGroups{}
+		byStock = dividendGroups{}
+		byDepot = dividendGroups{}
+	)
+
+	for _, t := range transactions {
+		if !t.Type.IsIncome() || t.Date.Before(begin) || t.Date.After(end) {
+			continue
+		}
+		report.Amount = report.Amount.Add(t.Amount)
+		report.GrossAmount = report.GrossAmount.Add(t.GrossAmount())
+		byYear.add(fmt.Sprintf("%04d", t.Date.Year()), nil, t)
+		byMonth.add(t.Date.Format("2006-01"), nil, t)
+		byStock.add(t.Stock.ID(), t.Stock, t)
+		byDepot.add(t.Depot, nil, t)
+	}
+	report.ByYear = byYear.sorted()
+	report.ByMonth = byMonth.sorted()
+	report.ByStock = byStock.sorted()
+	report.ByDepot = byDepot.sorted()
+
+	var (
+		portfolio = PortfolioAt(transactions, stats, end)
+		yearAgo   = end.AddDate(-1, 0, 0)
+		trailing  = map[*Stock]decimal.Decimal{}
+	)
+	for _, t := range transactions {
+		if !t.Type.IsIncome() || !t.Date.After(yearAgo) || t.Date.After(end) {
+			continue
+		}
+		ps := portfolio.Stocks[t.Stock]
+		if ps == nil || !ps.Shares().IsPositive() {
+			continue
+		}
+		trailing[t.Stock] = trailing[t.Stock].Add(t.Amount)
+
+		paid := stats[t].Portfolio.Stocks[t.Stock]
+		if paid == nil || !paid.Shares().IsPositive() {
+			continue
+		}
+		shares := splitAdjustedShares(transactions, t.Stock, paid.Shares(), t.Date, end)
+		report.Forecast = append(report.Forecast, DividendForecast{
+			Date:   t.Date.AddDate(1, 0, 0),
+			Stock:  t.Stock,
+			Amount: t.Amount.Div(shares).Mul(ps.Shares()),
+		})
+	}
+	sort.SliceStable(report.Forecast, func(i, j int) bool {
+		return report.Forecast[i].Date.Before(report.Forecast[j].Date)
+	})
+
+	for stock, dividends := range trailing {
+		ps := portfolio.Stocks[stock]
+		report.Yields = append(report.Yields, DividendYield{
+			Stock:        stock,
+			Shares:       ps.Shares(),
+			Dividends:    dividends,
+			YieldOnCost:  yield(dividends, ps.Invested()),
+			CurrentYield: yield(dividends, UnitPrice(price, stock, end).Mul(ps.Shares())),
+		})
+	}
+	sort.Slice(report.Yields, func(i, j int) bool {
+		return report.Yields[i].Stock.ID() < report.Yields[j].Stock.ID()
+	})
+
+	return report
+}
+
+func yield(dividends, base decimal.Decimal) float64 {
+	if base.IsZero() {
+		return 0
+	}
+	return Float64(dividends.Div(base))
+}
+
+// splitAdjustedShares returns shares of stock held on date adjusted for the
+// splits after date up to end.
+func splitAdjustedShares(transactions Transactions, stock *Stock, shares decimal.Decimal, date, end time.Time) decimal.Decimal {
+	for _, t := range transactions {
+		if t.Stock == stock && t.Type == Split && t.Date.After(date) && !t.Date.After(end) {
+			shares = t.Ratio.Shares(shares)
+		}
+	}
+	return shares
+}
+
+type dividendGroups map[string]*DividendGroup
+
+func (g dividendGroups) add(key string, stock *Stock, t *Transaction) {
+	group, ok := g[key]
+	if !ok {
+		group = &DividendGroup{Key: key, Stock: stock}
+		g[key] = group
+	}
+	group.Amount = group.Amount.Add(t.Amount)
+	group.GrossAmount = group.GrossAmount.Add(t.GrossAmount())
+}
+
+func (g dividendGroups) sorted() []DividendGroup {
+	groups := make([]DividendGroup, 0, len(g))
+	for _, group := range g {
+		groups = append(groups, *group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Key < groups[j].Key
+	})
+	return groups
+}
@@ -0,0 +1,97 @@
+package cf
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestCalculateDividendReport(t *testing.T) {
+	stock := &Stock{ISIN: "US1912161007"}
+	stock.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2019, 1, 1),
+			Amount: decimal.RequireFromString("-1000"),
+			Shares: decimal.RequireFromString("-10"),
+			Depot:  "comdirect",
+			Stock:  stock,
+		},
+		{
+			Type:   Dividend,
+			Date:   Date(2019, 6, 1),
+			Amount: decimal.RequireFromString("20"),
+			Taxes:  decimal.RequireFromString("5"),
+			Depot:  "comdirect",
+			Stock:  stock,
+		},
+		{
+			Type:   Dividend,
+			Date:   Date(2019, 12, 1),
+			Amount: decimal.RequireFromString("20"),
+			Depot:  "comdirect",
+			Stock:  stock,
+		},
+		{
+			Type:  Split,
+			Date:  Date(2020, 1, 15),
+			Ratio: Ratio{New: decimal.NewFromInt(2), Old: decimal.NewFromInt(1)},
+			Stock: stock,
+		},
+		{
+			Type:   Dividend,
+			Date:   Date(2020, 6, 1),
+			Amount: decimal.RequireFromString("22"),
+			Depot:  "comdirect",
+			Stock:  stock,
+		},
+	}
+
+	transactions, stats, err := CalculateStats([]*Stock{stock}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	price := func(stock *Stock, date time.Time) decimal.Decimal {
+		return decimal.NewFromInt(100)
+	}
+
+	report := CalculateDividendReport(price, transactions, stats, time.Time{}, Date(2020, 10, 1))
+
+	testCases := map[string]struct {
+		Actual   decimal.Decimal
+		Expected string
+	}{
+		"amount":          {report.Amount, "62"},
+		"gross amount":    {report.GrossAmount, "67"},
+		"2019":            {report.ByYear[0].Amount, "40"},
+		"2020":            {report.ByYear[1].Amount, "22"},
+		"stock":           {report.ByStock[0].Amount, "62"},
+		"depot":           {report.ByDepot[0].Amount, "62"},
+		"trailing":        {report.Yields[0].Dividends, "42"},
+		"forecast amount": {report.ForecastAmount(), "42"},
+		"first forecast":  {report.Forecast[0].Amount, "20"},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if expected := decimal.RequireFromString(testCase.Expected); !testCase.Actual.Equal(expected) {
+				t.Fatalf("expected %s, got %s", expected, testCase.Actual)
+			}
+		})
+	}
+
+	if n := len(report.ByMonth); n != 3 {
+		t.Fatalf("expected 3 months, got %d", n)
+	}
+	if date := report.Forecast[0].Date; !date.Equal(Date(2020, 12, 1)) {
+		t.Fatalf("expected first forecast on 2020-12-01, got %s", date)
+	}
+	if y := report.Yields[0].YieldOnCost; math.Abs(y-0.042) > 1e-9 {
+		t.Fatalf("expected yield on cost 0.042, got %f", y)
+	}
+	if y := report.Yields[0].CurrentYield; math.Abs(y-0.021) > 1e-9 {
+		t.Fatalf("expected current yield 0.021, got %f", y)
+	}
+}
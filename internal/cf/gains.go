@@ -0,0 +1,83 @@
+package cf
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// RealizedGain is a lot sold by a sell or covered by a cover transaction, or
+// closed by an exercise or assignment.
+type RealizedGain struct {
+	SoldLot
+	Stock       *Stock
+	Depot       string
+	Transaction *Transaction
+}
+
+// CalculateRealizedGains returns the lots closed between begin and end in
+// chronological order.
+func CalculateRealizedGains(transactions Transactions, stats map[*Transaction]Stats, begin, end time.Time) []RealizedGain {
+	var gains []RealizedGain
+	for _, t := range transactions {
+		switch t.Type {
+		case Sell, Cover, Exercise, Assignment:
+		default:
+			continue
+		}
+		if t.Date.Before(begin) || t.Date.After(end) {
+			continue
+		}
+		for _, lot := range stats[t].Sell.Lots {
+			gains = append(gains, RealizedGain{
+				SoldLot:     lot,
+				Stock:       t.Stock,
+				Depot:       t.Depot,
+				Transaction: t,
+			})
+		}
+	}
+	return gains
+}
+
+// RealizedGainsYear sums up the realized gains of a calendar year. Profits
+// and losses are net of fees and taxes.
+type RealizedGainsYear struct {
+	Year      int
+	Proceeds  decimal.Decimal
+	CostBasis decimal.Decimal
+	Fees      decimal.Decimal
+	Taxes     decimal.Decimal
+	Profit    decimal.Decimal
+	Loss      decimal.Decimal
+	ShortTerm decimal.Decimal
+	LongTerm  decimal.Decimal
+}
+
+// SummarizeRealizedGains sums up gains by the calendar year of the sell.
+// gains must be in chronological order.
+func SummarizeRealizedGains(gains []RealizedGain) []RealizedGainsYear {
+	var years []RealizedGainsYear
+	for _, g := range gains {
+		year := g.Sold.Year()
+		if len(years) == 0 || years[len(years)-1].Year != year {
+			years = append(years, RealizedGainsYear{Year: year})
+		}
+		y := &years[len(years)-1]
+		y.Proceeds = y.Proceeds.Add(g.Proceeds)
+		y.CostBasis = y.CostBasis.Add(g.CostBasis)
+		y.Fees = y.Fees.Add(g.Fees)
+		y.Taxes = y.Taxes.Add(g.Taxes)
+		if g.Profit.IsNegative() {
+			y.Loss = y.Loss.Add(g.Profit)
+		} else {
+			y.Profit = y.Profit.Add(g.Profit)
+		}
+		if g.LongTerm() {
+			y.LongTerm = y.LongTerm.Add(g.Profit)
+		} else {
+			y.ShortTerm = y.ShortTerm.Add(g.Profit)
+		}
+	}
+	return years
+}
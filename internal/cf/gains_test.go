@@ -0,0 +1,84 @@
+package cf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestCalculateRealizedGains(t *testing.T) {
+	stock := &Stock{ISIN: "US88160R1014"}
+	stock.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2019, 1, 1),
+			Amount: decimal.RequireFromString("-1010"),
+			Shares: decimal.RequireFromString("-10"),
+			Fees:   decimal.RequireFromString("10"),
+			Stock:  stock,
+		},
+		{
+			Type:   Buy,
+			Date:   Date(2020, 1, 1),
+			Amount: decimal.RequireFromString("-2000"),
+			Shares: decimal.RequireFromString("-10"),
+			Stock:  stock,
+		},
+		{
+			Type:   Sell,
+			Date:   Date(2020, 6, 1),
+			Amount: decimal.RequireFromString("2250"),
+			Shares: decimal.RequireFromString("15"),
+			Fees:   decimal.RequireFromString("15"),
+			Stock:  stock,
+		},
+	}
+
+	transactions, stats, err := CalculateStats([]*Stock{stock}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gains := CalculateRealizedGains(transactions, stats, time.Time{}, Date(2020, 12, 31))
+	if len(gains) != 2 {
+		t.Fatalf("expected 2 gains, got %d", len(gains))
+	}
+
+	testCases := map[string]struct {
+		Actual   decimal.Decimal
+		Expected string
+	}{
+		"first proceeds":    {gains[0].Proceeds, "1500"},
+		"first cost basis":  {gains[0].CostBasis, "1010"},
+		"first fees":        {gains[0].Fees, "20"},
+		"first profit":      {gains[0].Profit, "490"},
+		"second shares":     {gains[1].Shares, "5"},
+		"second proceeds":   {gains[1].Proceeds, "750"},
+		"second cost basis": {gains[1].CostBasis, "1000"},
+		"second profit":     {gains[1].Profit, "-250"},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if expected := decimal.RequireFromString(testCase.Expected); !testCase.Actual.Equal(expected) {
+				t.Fatalf("expected %s, got %s", expected, testCase.Actual)
+			}
+		})
+	}
+
+	if !gains[0].LongTerm() || gains[1].LongTerm() {
+		t.Fatal("expected first lot to be long-term and second lot to be short-term")
+	}
+
+	years := SummarizeRealizedGains(gains)
+	if len(years) != 1 {
+		t.Fatalf("expected 1 year, got %d", len(years))
+	}
+	y := years[0]
+	if !y.Profit.Equal(decimal.NewFromInt(490)) || !y.Loss.Equal(decimal.NewFromInt(-250)) {
+		t.Fatalf("expected profit 490 and loss -250, got %s and %s", y.Profit, y.Loss)
+	}
+	if !y.LongTerm.Equal(decimal.NewFromInt(490)) || !y.ShortTerm.Equal(decimal.NewFromInt(-250)) {
+		t.Fatalf("expected long-term 490 and short-term -250, got %s and %s", y.LongTerm, y.ShortTerm)
+	}
+}
@@ -0,0 +1,226 @@
+package cf
+
+import (
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// HarvestLot is an open lot with an unrealized loss. CumulativeShares and
+// CumulativeResult are the shares and the profit or loss realized by a sell
+// of all lots up to and including this one in the order of the lot method.
+type HarvestLot struct {
+	Stock            *Stock
+	Depot            string
+	Lot              string
+	Acquired         time.Time
+	Shares           decimal.Decimal
+	CostBasis        decimal.Decimal
+	Value            decimal.Decimal
+	Loss             decimal.Decimal
+	HoldingDays      int
+	LongTerm         bool
+	CumulativeShares decimal.Decimal
+	CumulativeResult decimal.Decimal
+}
+
+// HarvestSale is a suggested sell. Lot is only set for stocks sold by
+// specific lot.
+type HarvestSale struct {
+	Stock     *Stock
+	Depot     string
+	Lot       string
+	Shares    decimal.Decimal
+	Proceeds  decimal.Decimal
+	CostBasis decimal.Decimal
+	Loss      decimal.Decimal
+}
+
+// Harvest lists the tax-loss harvesting candidates of a portfolio and the
+// sells suggested to realize a loss offsetting Target.
+type Harvest struct {
+	Lots   []HarvestLot
+	Sales  []HarvestSale
+	Target decimal.Decimal
+	Loss   decimal.Decimal
+}
+
+// harvestGroup is a sequence of batches which can only be sold in order.
+type harvestGroup struct {
+	stock   *Stock
+	depot   string
+	lot     string
+	price   decimal.Decimal
+	batches []PortfolioStockBatch
+	best    int // length of the prefix realizing the largest loss
+	loss    decimal.Decimal
+}
+
+// CalculateHarvest lists the open lots of portfolio with an unrealized loss
+// on date and suggests sells realizing a loss of target. Lots are sold in the
+// order of the lot method of their stock and depot, so a suggested sell may
+// include lots with gains sold before a lot with a loss. Lots of stocks sold
+// by specific lot can be sold individually. Whole shares are sold from lots
+// with whole shares.
+func CalculateHarvest(price PriceFunc, portfolio Portfolio, date time.Time, target decimal.Decimal) Harvest {
+	harvest := Harvest{Target: target}
+
+	var groups []*harvestGroup
+	for s, ps := range portfolio.Stocks {
+		p := UnitPrice(price, s, date)
+		if !p.IsPositive() {
+			continue
+		}
+		for _, depot := range ps.depots() {
+			method := portfolio.LotMethod(s, depot)
+			ordered := ps
+			if method == AverageCost {
+				ordered = ps.Clone()
+				ordered.average(depot)
+			}
+
+			var batches []PortfolioStockBatch
+			for _, i := range ordered.lots(&Transaction{Depot: depot}, method) {
+				batches = append(batches, ordered.Batches[i])
+			}
+
+			if method == SpecificLot {
+				for _, b := range batches {
+					groups = append(groups, newHarvestGroup(s, depot, b.Lot, p, []PortfolioStockBatch{b}))
+				}
+			} else {
+				groups = append(groups, newHarvestGroup(s, depot, "", p, batches))
+			}
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		a, b := groups[i], groups[j]
+		if a.stock.ID() != b.stock.ID() {
+			return a.stock.ID() < b.stock.ID()
+		}
+		if a.depot != b.depot {
+			return a.depot < b.depot
+		}
+		return a.lot < b.lot
+	})
+
+	for _, g := range groups {
+		harvest.Lots = append(harvest.Lots, g.lossLots(date)...)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].loss.LessThan(groups[j].loss)
+	})
+	remaining := target
+	for _, g := range groups {
+		if !remaining.IsPositive() || !g.loss.IsNegative() {
+			break
+		}
+		sale := g.sell(remaining)
+		harvest.Sales = append(harvest.Sales, sale)
+		harvest.Loss = harvest.Loss.Add(sale.Loss)
+		remaining = remaining.Add(sale.Loss)
+	}
+
+	return harvest
+}
+
+func newHarvestGroup(s *Stock, depot, lot string, price decimal.Decimal, batches []PortfolioStockBatch) *harvestGroup {
+	g := &harvestGroup{
+		stock:   s,
+		depot:   depot,
+		lot:     lot,
+		price:   price,
+		batches: batches,
+	}
+	result := decimal.Zero
+	for i, b := range batches {
+		result = result.Add(g.result(b))
+		if result.LessThan(g.loss) {
+			g.best, g.loss = i+1, result
+		}
+	}
+	return g
+}
+
+// result returns the profit or loss of selling batch b.
+func (g *harvestGroup) result(b PortfolioStockBatch) decimal.Decimal {
+	return g.price.Mul(b.Shares).Sub(b.Invested())
+}
+
+func (g *harvestGroup) lossLots(date time.Time) []HarvestLot {
+	var (
+		lots   []HarvestLot
+		shares = decimal.Zero
+		result = decimal.Zero
+	)
+	for _, b := range g.batches {
+		r := g.result(b)
+		shares = shares.Add(b.Shares)
+		result = result.Add(r)
+		if !r.IsNegative() {
+			continue
+		}
+		lots = append(lots, HarvestLot{
+			Stock:            g.stock,
+			Depot:            g.depot,
+			Lot:              b.Lot,
+			Acquired:         b.Date,
+			Shares:           b.Shares,
+			CostBasis:        b.Invested(),
+			Value:            g.price.Mul(b.Shares),
+			Loss:             r,
+			HoldingDays:      days(b.Date, date),
+			LongTerm:         date.After(b.Date.AddDate(1, 0, 0)),
+			CumulativeShares: shares,
+			CumulativeResult: result,
+		})
+	}
+	return lots
+}
+
+// sell returns the sell of the group realizing a loss of up to amount.
+func (g *harvestGroup) sell(amount decimal.Decimal) HarvestSale {
+	sale := HarvestSale{
+		Stock: g.stock,
+		Depot: g.depot,
+		Lot:   g.lot,
+	}
+	for _, b := range g.batches[:g.best] {
+		r := g.result(b)
+		if r.IsNegative() && sale.Loss.Add(r).Neg().GreaterThan(amount) {
+			shares := amount.Add(sale.Loss).Div(r.Neg().Div(b.Shares))
+			if b.Shares.Equal(b.Shares.Truncate(0)) {
+				shares = shares.Ceil()
+			}
+			b = b.part(decimal.Min(shares, b.Shares))
+			r = g.result(b)
+			sale.add(g.price, b, r)
+			break
+		}
+		sale.add(g.price, b, r)
+	}
+	return sale
+}
+
+func (s *HarvestSale) add(price decimal.Decimal, b PortfolioStockBatch, result decimal.Decimal) {
+	s.Shares = s.Shares.Add(b.Shares)
+	s.Proceeds = s.Proceeds.Add(price.Mul(b.Shares))
+	s.CostBasis = s.CostBasis.Add(b.Invested())
+	s.Loss = s.Loss.Add(result)
+}
+
+// depots returns the depots of the batches in alphabetical order.
+func (ps *PortfolioStock) depots() []string {
+	seen := map[string]bool{}
+	var depots []string
+	for _, b := range ps.Batches {
+		if !seen[b.Depot] {
+			seen[b.Depot] = true
+			depots = append(depots, b.Depot)
+		}
+	}
+	sort.Strings(depots)
+	return depots
+}
@@ -0,0 +1,107 @@
+package cf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestCalculateHarvest(t *testing.T) {
+	var (
+		fifo     = &Stock{ISIN: "US0000000001"}
+		specific = &Stock{ISIN: "US0000000002", LotMethod: SpecificLot}
+	)
+	fifo.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2020, 1, 1),
+			Amount: decimal.RequireFromString("-1000"),
+			Shares: decimal.RequireFromString("-10"),
+			Stock:  fifo,
+		},
+		{
+			Type:   Buy,
+			Date:   Date(2020, 6, 1),
+			Amount: decimal.RequireFromString("-1500"),
+			Shares: decimal.RequireFromString("-10"),
+			Stock:  fifo,
+		},
+	}
+	specific.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2020, 1, 1),
+			Amount: decimal.RequireFromString("-500"),
+			Shares: decimal.RequireFromString("-10"),
+			Lot:    "L1",
+			Stock:  specific,
+		},
+		{
+			Type:   Buy,
+			Date:   Date(2020, 2, 1),
+			Amount: decimal.RequireFromString("-800"),
+			Shares: decimal.RequireFromString("-10"),
+			Lot:    "L2",
+			Stock:  specific,
+		},
+	}
+
+	transactions, stats, err := CalculateStats([]*Stock{fifo, specific}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	price := func(s *Stock, date time.Time) decimal.Decimal {
+		if s == fifo {
+			return decimal.NewFromInt(120)
+		}
+		return decimal.NewFromInt(40)
+	}
+
+	date := Date(2020, 12, 1)
+	harvest := CalculateHarvest(price, PortfolioAt(transactions, stats, date), date, decimal.NewFromInt(450))
+
+	if n := len(harvest.Lots); n != 3 {
+		t.Fatalf("expected 3 lots, got %d", n)
+	}
+	if n := len(harvest.Sales); n != 2 {
+		t.Fatalf("expected 2 sales, got %d", n)
+	}
+
+	var (
+		lot    = harvest.Lots[0]
+		first  = harvest.Sales[0]
+		second = harvest.Sales[1]
+	)
+
+	testCases := map[string]struct {
+		Actual   decimal.Decimal
+		Expected string
+	}{
+		"lot loss":              {lot.Loss, "-300"},
+		"lot cumulative shares": {lot.CumulativeShares, "20"},
+		"lot cumulative result": {lot.CumulativeResult, "-100"},
+		"first sale shares":     {first.Shares, "10"},
+		"first sale loss":       {first.Loss, "-400"},
+		// The first FIFO lot with a gain of 200 has to be sold before 9
+		// shares of the second lot with a loss of 270.
+		"second sale shares": {second.Shares, "19"},
+		"second sale loss":   {second.Loss, "-70"},
+		"loss":               {harvest.Loss, "-470"},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if expected := decimal.RequireFromString(testCase.Expected); !testCase.Actual.Equal(expected) {
+				t.Fatalf("expected %s, got %s", expected, testCase.Actual)
+			}
+		})
+	}
+
+	if first.Stock != specific || first.Lot != "L2" {
+		t.Fatalf("expected first sale of lot L2, got %s %s", first.Stock.ISIN, first.Lot)
+	}
+	if second.Stock != fifo || second.Lot != "" {
+		t.Fatalf("expected second sale of FIFO stock, got %s %s", second.Stock.ISIN, second.Lot)
+	}
+}
@@ -0,0 +1,103 @@
+package cf
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Interval is the distance between the sample dates of a history or the
+// executions of a savings plan.
+type Interval string
+
+const (
+	Daily     Interval = "day"
+	Weekly    Interval = "week"
+	Monthly   Interval = "month"
+	Quarterly Interval = "quarter"
+)
+
+// ParseInterval parses an interval. An empty string means Daily.
+func ParseInterval(s string) (Interval, error) {
+	switch interval := Interval(strings.ToLower(s)); interval {
+	case "":
+		return Daily, nil
+	case Daily, Weekly, Monthly, Quarterly:
+		return interval, nil
+	default:
+		return "", fmt.Errorf("cf: invalid interval %q", s)
+	}
+}
+
+func (i Interval) next(date time.Time) time.Time {
+	switch i {
+	case Weekly:
+		return date.AddDate(0, 0, 7)
+	case Monthly:
+		return date.AddDate(0, 1, 0)
+	case Quarterly:
+		return date.AddDate(0, 3, 0)
+	default:
+		return date.AddDate(0, 0, 1)
+	}
+}
+
+// HistoryPoint is the state of a portfolio on a date. Return is the
+// cumulative time-weighted return since the first transaction.
+type HistoryPoint struct {
+	Date           time.Time
+	Value          decimal.Decimal
+	Invested       decimal.Decimal
+	RealizedProfit decimal.Decimal
+	Dividends      decimal.Decimal
+	CashBalance    decimal.Decimal
+	Return         float64
+}
+
+// CalculateHistory samples the portfolio between begin and end every
+// interval. The end date is always included. A zero begin means since the
+// first transaction. Stocks are valued at zero on dates without a price, so
+// their prices are needed from the first transaction on.
+func CalculateHistory(ctx context.Context, price PriceFunc, transactions Transactions, stats map[*Transaction]Stats, begin, end time.Time, interval Interval) []HistoryPoint {
+	if len(transactions) == 0 {
+		return nil
+	}
+	first := transactions[0].Date
+	if begin.Before(first) {
+		begin = first
+	}
+
+	// The return is chain-linked from the return up to the previous sample
+	// and the return since it, which equals the return since the first
+	// transaction.
+	var (
+		history []HistoryPoint
+		growth  = 1.0
+		from    = first
+	)
+	for date := begin; !date.After(end); {
+		portfolio := PortfolioAt(transactions, stats, date)
+		growth *= 1 + CalculateTWR(ctx, price, transactions, stats, from, date)
+		history = append(history, HistoryPoint{
+			Date:           date,
+			Value:          portfolioValue(price, portfolio, date),
+			Invested:       portfolio.Invested(),
+			RealizedProfit: portfolio.RealizedProfit(),
+			Dividends:      portfolio.Dividends(),
+			CashBalance:    portfolio.CashBalance(),
+			Return:         growth - 1,
+		})
+
+		if date.Equal(end) {
+			break
+		}
+		from = date.AddDate(0, 0, 1)
+		if date = interval.next(date); date.After(end) {
+			date = end
+		}
+	}
+	return history
+}
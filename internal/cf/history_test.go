@@ -0,0 +1,113 @@
+package cf
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestCalculateHistory(t *testing.T) {
+	stock := &Stock{ISIN: "US88160R1014"}
+	stock.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2020, 1, 1),
+			Amount: decimal.RequireFromString("-1000"),
+			Shares: decimal.RequireFromString("-10"),
+			Stock:  stock,
+		},
+		{
+			Type:   Sell,
+			Date:   Date(2020, 3, 1),
+			Amount: decimal.RequireFromString("600"),
+			Shares: decimal.RequireFromString("5"),
+			Stock:  stock,
+		},
+	}
+
+	transactions, stats, err := CalculateStats([]*Stock{stock}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	price := func(stock *Stock, date time.Time) decimal.Decimal {
+		switch {
+		case date.Before(Date(2020, 2, 1)):
+			return decimal.NewFromInt(100)
+		default:
+			return decimal.NewFromInt(120)
+		}
+	}
+
+	history := CalculateHistory(context.Background(), price, transactions, stats, time.Time{}, Date(2020, 3, 15), Monthly)
+
+	expected := []struct {
+		Date           time.Time
+		Value          string
+		Invested       string
+		RealizedProfit string
+		Return         float64
+	}{
+		{Date(2020, 1, 1), "1000", "1000", "0", 0},
+		{Date(2020, 2, 1), "1200", "1000", "0", 0.2},
+		{Date(2020, 3, 1), "600", "500", "100", 0.2},
+		{Date(2020, 3, 15), "600", "500", "100", 0.2},
+	}
+	if len(history) != len(expected) {
+		t.Fatalf("expected %d points, got %d", len(expected), len(history))
+	}
+	for i, e := range expected {
+		p := history[i]
+		if !p.Date.Equal(e.Date) {
+			t.Errorf("point %d: expected date %s, got %s", i, e.Date, p.Date)
+		}
+		if v := decimal.RequireFromString(e.Value); !p.Value.Equal(v) {
+			t.Errorf("point %d: expected value %s, got %s", i, v, p.Value)
+		}
+		if v := decimal.RequireFromString(e.Invested); !p.Invested.Equal(v) {
+			t.Errorf("point %d: expected invested %s, got %s", i, v, p.Invested)
+		}
+		if v := decimal.RequireFromString(e.RealizedProfit); !p.RealizedProfit.Equal(v) {
+			t.Errorf("point %d: expected realized profit %s, got %s", i, v, p.RealizedProfit)
+		}
+		if math.Abs(p.Return-e.Return) > 1e-9 {
+			t.Errorf("point %d: expected return %f, got %f", i, e.Return, p.Return)
+		}
+	}
+}
+
+func TestCalculateHistoryChainLinked(t *testing.T) {
+	stock := &Stock{ISIN: "US88160R1014"}
+	for i := 0; i < 12; i++ {
+		stock.Transactions = append(stock.Transactions, &Transaction{
+			Type:   Buy,
+			Date:   Date(2020, i+1, 10),
+			Amount: decimal.NewFromInt(int64(-1000 - 100*i)),
+			Shares: decimal.NewFromInt(-10),
+			Stock:  stock,
+		})
+	}
+
+	transactions, stats, err := CalculateStats([]*Stock{stock}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	price := func(stock *Stock, date time.Time) decimal.Decimal {
+		return decimal.NewFromInt(int64(100 + date.YearDay()%17))
+	}
+
+	var (
+		ctx     = context.Background()
+		first   = transactions[0].Date
+		history = CalculateHistory(ctx, price, transactions, stats, Date(2020, 2, 3), Date(2020, 12, 31), Weekly)
+	)
+	for _, p := range history {
+		if expected := CalculateTWR(ctx, price, transactions, stats, first, p.Date); math.Abs(p.Return-expected) > 1e-9 {
+			t.Fatalf("%s: expected return %f, got %f", p.Date.Format("2006-01-02"), expected, p.Return)
+		}
+	}
+}
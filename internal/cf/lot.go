@@ -0,0 +1,158 @@
+package cf
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// LotMethod determines which batches of a stock are sold first.
+type LotMethod string
+
+const (
+	// FIFO sells the oldest batches first.
+	FIFO LotMethod = "fifo"
+	// LIFO sells the newest batches first.
+	LIFO LotMethod = "lifo"
+	// HIFO sells the batches with the highest price per share first.
+	HIFO LotMethod = "hifo"
+	// SpecificLot sells the batch named by the sell transaction's Lot and
+	// falls back to FIFO for sells without a lot.
+	SpecificLot LotMethod = "specific"
+	// AverageCost sells at the average price per share of all batches in
+	// the depot.
+	AverageCost LotMethod = "average"
+)
+
+func ParseLotMethod(s string) (LotMethod, error) {
+	switch m := LotMethod(s); m {
+	case FIFO, LIFO, HIFO, SpecificLot, AverageCost:
+		return m, nil
+	case "":
+		return "", nil
+	default:
+		return "", fmt.Errorf("cf: invalid lot method %q", s)
+	}
+}
+
+// LotMethod returns the lot method for selling stock s in depot. The lot
+// method of the depot takes precedence, as it may be required by law, and the
+// lot method of the stock only applies to depots without one; the default is
+// FIFO.
+func (p Portfolio) LotMethod(s *Stock, depot string) LotMethod {
+	if d := p.Depots[depot]; d != nil && d.LotMethod != "" {
+		return d.LotMethod
+	}
+	if s != nil && s.LotMethod != "" {
+		return s.LotMethod
+	}
+	return FIFO
+}
+
+// checkLot returns an error if t names a lot although method does not sell
+// by specific lot.
+func checkLot(t *Transaction, method LotMethod) error {
+	if t.Lot != "" && method != SpecificLot {
+		return fmt.Errorf("cf: invalid transaction: lot %s with lot method %s", t.Lot, method)
+	}
+	return nil
+}
+
+// lots returns the indexes of the long batches t sells from in the order they
+// are sold according to method. With SpecificLot, a sell naming a lot only
+// sells from that lot.
+func (ps *PortfolioStock) lots(t *Transaction, method LotMethod) []int {
+	return ps.orderedLots(t, method, false)
+}
+
+// shortLots returns the indexes of the short batches t covers in the order
+// they are covered according to method. With SpecificLot, a cover naming a
+// lot only covers that lot.
+func (ps *PortfolioStock) shortLots(t *Transaction, method LotMethod) []int {
+	return ps.orderedLots(t, method, true)
+}
+
+func (ps *PortfolioStock) orderedLots(t *Transaction, method LotMethod, short bool) []int {
+	byLot := t.Lot != "" && method == SpecificLot
+
+	var lots []int
+	for i, b := range ps.Batches {
+		if b.Depot != t.Depot || b.Shares.IsNegative() != short {
+			continue
+		}
+		if byLot && b.Lot != t.Lot {
+			continue
+		}
+		lots = append(lots, i)
+	}
+
+	if byLot {
+		return lots
+	}
+
+	sort.SliceStable(lots, func(i, j int) bool {
+		a, b := ps.Batches[lots[i]], ps.Batches[lots[j]]
+		switch method {
+		case LIFO:
+			return a.Date.After(b.Date)
+		case HIFO:
+			if !a.PricePerShare.Equal(b.PricePerShare) {
+				return a.PricePerShare.GreaterThan(b.PricePerShare)
+			}
+		}
+		return a.Date.Before(b.Date)
+	})
+	return lots
+}
+
+// newLot returns the name of a new lot acquired on date, which is the date
+// followed by a sequence number if there already is a lot of that name.
+func (ps *PortfolioStock) newLot(date time.Time) string {
+	lot := date.Format("2006-01-02")
+	for n := 2; ps.hasLot(lot); n++ {
+		lot = fmt.Sprintf("%s-%d", date.Format("2006-01-02"), n)
+	}
+	return lot
+}
+
+func (ps *PortfolioStock) hasLot(lot string) bool {
+	for _, b := range ps.Batches {
+		if b.Lot == lot {
+			return true
+		}
+	}
+	return false
+}
+
+// average sets the price per share of all long batches in depot to their
+// average price per share and distributes fees and taxes evenly across
+// shares.
+func (ps *PortfolioStock) average(depot string) {
+	var (
+		shares   = decimal.Zero
+		invested = decimal.Zero
+		fees     = decimal.Zero
+		taxes    = decimal.Zero
+	)
+	for _, b := range ps.Batches {
+		if b.Depot == depot && b.Shares.IsPositive() {
+			shares = shares.Add(b.Shares)
+			invested = invested.Add(b.Invested())
+			fees = fees.Add(b.Fees)
+			taxes = taxes.Add(b.Taxes)
+		}
+	}
+	if shares.IsZero() {
+		return
+	}
+	pricePerShare := invested.Div(shares)
+	for i, b := range ps.Batches {
+		if b.Depot == depot && b.Shares.IsPositive() {
+			ps.Batches[i].PricePerShare = pricePerShare
+			ps.Batches[i].Fees = fees.Mul(b.Shares).Div(shares)
+			ps.Batches[i].Taxes = taxes.Mul(b.Shares).Div(shares)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package cf
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// OptionType is the right of an option or warrant.
+type OptionType string
+
+const (
+	Call OptionType = "call"
+	Put  OptionType = "put"
+)
+
+func ParseOptionType(s string) (OptionType, error) {
+	switch ot := OptionType(s); ot {
+	case Call, Put:
+		return ot, nil
+	default:
+		return "", fmt.Errorf("cf: invalid option type %q", s)
+	}
+}
+
+// IsDerivative returns whether the stock is an option or warrant.
+func (s *Stock) IsDerivative() bool {
+	return s.Kind == OptionKind || s.Kind == WarrantKind
+}
+
+// ExpandOptions adds the trades of the underlying of exercised and assigned
+// options and warrants among stocks and closes the contracts still open on
+// their expiry at zero, if it is on or before until.
+//
+// Exercising a call or being assigned a put buys ContractSize shares of the
+// underlying per contract at the strike, and exercising a put or being
+// assigned a call sells them. The trades are added to the underlying in the
+// depot of the exercise or assignment, with the option as their Source.
+func ExpandOptions(stocks []*Stock, until time.Time) error {
+	byID := map[string]*Stock{}
+	for _, s := range stocks {
+		byID[s.ID()] = s
+	}
+
+	expanded := map[*Stock]bool{}
+	for _, s := range stocks {
+		if !s.IsDerivative() {
+			continue
+		}
+
+		for _, t := range s.Transactions {
+			if t.Type != Exercise && t.Type != Assignment {
+				continue
+			}
+			underlying := byID[s.Underlying]
+			if underlying == nil {
+				return fmt.Errorf("cf: underlying %q of %s not found", s.Underlying, s.ID())
+			}
+			underlying.Transactions = append(underlying.Transactions, s.underlyingTrade(t, underlying))
+			expanded[underlying] = true
+		}
+
+		if !s.Expiry.IsZero() && !s.Expiry.After(until) {
+			s.Transactions = append(s.Transactions, s.expire()...)
+			expanded[s] = true
+		}
+	}
+
+	for s := range expanded {
+		s.Transactions.Sort()
+	}
+	return nil
+}
+
+// underlyingTrade returns the buy or sell of underlying at the strike by the
+// exercise or assignment t.
+func (s *Stock) underlyingTrade(t *Transaction, underlying *Stock) *Transaction {
+	var (
+		shares = t.Shares.Abs().Mul(s.Multiplier())
+		amount = shares.Mul(s.Strike)
+		buy    = (s.OptionType == Call) == (t.Type == Exercise)
+	)
+	trade := &Transaction{
+		Type:   Sell,
+		Date:   t.Date,
+		Amount: amount,
+		Shares: shares,
+		Depot:  t.Depot,
+		Source: s.ID(),
+		Stock:  underlying,
+	}
+	if buy {
+		trade.Type = Buy
+		trade.Amount = amount.Neg()
+		trade.Shares = shares.Neg()
+	}
+	return trade
+}
+
+// expire returns the transactions closing the contracts of the stock open on
+// its expiry at zero: sells of held and covers of written contracts.
+func (s *Stock) expire() Transactions {
+	p := NewPortfolio(nil)
+	for _, t := range s.Transactions {
+		if !t.Date.After(s.Expiry) {
+			p.apply(s, t)
+		}
+	}
+	ps := p.Stocks[s]
+	if ps == nil {
+		return nil
+	}
+
+	var expired Transactions
+	for _, depot := range ps.depots() {
+		shares := decimal.Zero
+		for _, b := range ps.Batches {
+			if b.Depot == depot {
+				shares = shares.Add(b.Shares)
+			}
+		}
+		t := &Transaction{
+			Type:   Sell,
+			Date:   s.Expiry,
+			Amount: decimal.Zero,
+			Shares: shares,
+			Depot:  depot,
+			Stock:  s,
+		}
+		switch {
+		case shares.IsZero():
+			continue
+		case shares.IsNegative():
+			t.Type = Cover
+		}
+		expired = append(expired, t)
+	}
+	return expired
+}
@@ -0,0 +1,133 @@
+package cf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestOptions(t *testing.T) {
+	var (
+		underlying = &Stock{ISIN: "US0378331005", Name: "Apple"}
+		call       = &Stock{
+			Symbol:     "AAPL230120C00150000",
+			Kind:       OptionKind,
+			Underlying: "US0378331005",
+			OptionType: Call,
+			Strike:     decimal.RequireFromString("150"),
+			Expiry:     Date(2023, 1, 20),
+		}
+		put = &Stock{
+			Symbol:       "AAPL230120P00120000",
+			Kind:         OptionKind,
+			Underlying:   "US0378331005",
+			OptionType:   Put,
+			Strike:       decimal.RequireFromString("120"),
+			Expiry:       Date(2023, 1, 20),
+			ContractSize: decimal.RequireFromString("100"),
+		}
+	)
+	underlying.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2022, 1, 3),
+			Amount: decimal.RequireFromString("-14000"),
+			Shares: decimal.RequireFromString("-100"),
+			Stock:  underlying,
+		},
+	}
+	call.ContractSize = decimal.RequireFromString("100")
+	call.Transactions = Transactions{
+		{
+			Type:   Short,
+			Date:   Date(2022, 6, 1),
+			Amount: decimal.RequireFromString("450"),
+			Shares: decimal.RequireFromString("1"),
+			Stock:  call,
+		},
+		{
+			Type:   Assignment,
+			Date:   Date(2023, 1, 20),
+			Shares: decimal.RequireFromString("-1"),
+			Stock:  call,
+		},
+	}
+	put.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2022, 6, 1),
+			Amount: decimal.RequireFromString("-210"),
+			Shares: decimal.RequireFromString("-1"),
+			Stock:  put,
+		},
+	}
+
+	stocks := []*Stock{underlying, call, put}
+	if err := ExpandOptions(stocks, Date(2023, 6, 30)); err != nil {
+		t.Fatal(err)
+	}
+	if len(underlying.Transactions) != 2 || len(put.Transactions) != 2 || len(call.Transactions) != 2 {
+		t.Fatalf("expected 2 transactions each, got %d, %d and %d",
+			len(underlying.Transactions), len(call.Transactions), len(put.Transactions))
+	}
+
+	_, stats, err := CalculateStats(stocks, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		assigned = underlying.Transactions[1]
+		expired  = put.Transactions[1]
+		open     = stats[call.Transactions[0]]
+		price    = func(stock *Stock, date time.Time) decimal.Decimal {
+			if stock == underlying {
+				return decimal.RequireFromString("145")
+			}
+			return decimal.RequireFromString("3.5")
+		}
+	)
+
+	testCases := map[string]struct {
+		Actual   decimal.Decimal
+		Expected string
+	}{
+		"multiplier":          {call.Multiplier(), "100"},
+		"covered call value":  {open.Portfolio.Value(price, Date(2022, 6, 1)), "14150"},
+		"assigned shares":     {assigned.Shares, "100"},
+		"assigned amount":     {assigned.Amount, "15000"},
+		"underlying profit":   {stats[assigned].Sell.Profit, "1000"},
+		"premium profit":      {stats[call.Transactions[1]].Sell.Profit, "450"},
+		"expired shares":      {expired.Shares, "1"},
+		"expired amount":      {expired.Amount, "0"},
+		"expired profit":      {stats[expired].Sell.Profit, "-210"},
+		"final realized":      {stats[expired].Portfolio.RealizedProfit(), "1240"},
+		"final shares":        {stats[expired].Portfolio.Stocks[underlying].Shares(), "0"},
+		"final option shares": {stats[expired].Portfolio.Stocks[put].Shares(), "0"},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if expected := decimal.RequireFromString(testCase.Expected); !testCase.Actual.Equal(expected) {
+				t.Fatalf("expected %s, got %s", expected, testCase.Actual)
+			}
+		})
+	}
+
+	if assigned.Type != Sell || assigned.Source != call.ID() {
+		t.Fatalf("expected sell from %s, got %s from %s", call.ID(), assigned.Type, assigned.Source)
+	}
+	if expired.Type != Sell {
+		t.Fatalf("expected expired put to be sold, got %s", expired.Type)
+	}
+}
+
+func TestExpandOptionsMissingUnderlying(t *testing.T) {
+	option := &Stock{Symbol: "X", Kind: WarrantKind, Underlying: "missing", OptionType: Call}
+	option.Transactions = Transactions{
+		{Type: Exercise, Date: Date(2022, 1, 3), Shares: decimal.NewFromInt(1), Stock: option},
+	}
+	if err := ExpandOptions([]*Stock{option}, Date(2022, 12, 31)); err == nil {
+		t.Fatal("expected error for missing underlying")
+	}
+}
@@ -3,6 +3,7 @@ package cf
 import (
 	"context"
 	"math"
+	"sort"
 	"time"
 
 	"github.com/shopspring/decimal"
@@ -11,35 +12,51 @@ import (
 )
 
 type Performances struct {
-	Overall Performance
-	YTD     Performance
-	Today   Performance
-	IRR     float64
+	Overall     Performance
+	YTD         Performance
+	Today       Performance
+	IRR         float64
+	InvestorIRR float64
 }
 
+// Performance is the performance of a portfolio over a period. Return and
+// Profit are net of fees and taxes, GrossReturn and GrossProfit are not.
+// Profit is split into the PriceEffect caused by changing stock prices and
+// the CurrencyEffect caused by changing exchange rates. TWR is the
+// time-weighted return over the period.
 type Performance struct {
-	Return float64
-	Profit decimal.Decimal
+	Return         float64
+	Profit         decimal.Decimal
+	GrossReturn    float64
+	GrossProfit    decimal.Decimal
+	PriceEffect    decimal.Decimal
+	CurrencyEffect decimal.Decimal
+	TWR            float64
 }
 
-func CalculatePerformances(ctx context.Context, price PriceFunc, transactions Transactions, stats map[*Transaction]Stats) Performances {
+// CalculatePerformances calculates the overall, year-to-date and daily
+// performances as of the given date.
+func CalculatePerformances(ctx context.Context, price PriceFunc, conv *Converter, transactions Transactions, stats map[*Transaction]Stats, date time.Time) Performances {
 	if len(stats) == 0 {
 		return Performances{}
 	}
 	var (
-		now   = time.Now()
-		today = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-		jan1  = time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
+		today = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+		jan1  = time.Date(date.Year(), 1, 1, 0, 0, 0, 0, date.Location())
 	)
+	if transactions[0].Date.After(today) {
+		return Performances{}
+	}
 	return Performances{
-		Overall: CalculatePerformance(ctx, price, transactions, stats, transactions[0].Date, today),
-		YTD:     CalculatePerformance(ctx, price, transactions, stats, jan1, today),
-		Today:   CalculatePerformance(ctx, price, transactions, stats, today, today),
-		IRR:     CalculateIRR(ctx, price, transactions, stats, transactions[0].Date, today),
+		Overall:     CalculatePerformance(ctx, price, conv, transactions, stats, transactions[0].Date, today),
+		YTD:         CalculatePerformance(ctx, price, conv, transactions, stats, jan1, today),
+		Today:       CalculatePerformance(ctx, price, conv, transactions, stats, today, today),
+		IRR:         CalculateIRR(ctx, price, transactions, stats, transactions[0].Date, today),
+		InvestorIRR: CalculateInvestorIRR(ctx, price, transactions, stats, transactions[0].Date, today),
 	}
 }
 
-func CalculatePerformance(ctx context.Context, price PriceFunc, transactions Transactions, stats map[*Transaction]Stats, begin, end time.Time) Performance {
+func CalculatePerformance(ctx context.Context, price PriceFunc, conv *Converter, transactions Transactions, stats map[*Transaction]Stats, begin, end time.Time) Performance {
 	if len(stats) == 0 {
 		return Performance{}
 	}
@@ -49,32 +66,99 @@ func CalculatePerformance(ctx context.Context, price PriceFunc, transactions Tra
 		return Performance{}
 	}
 
+	twr := CalculateTWR(ctx, price, transactions, stats, begin, end)
+
 	portfolio := stats[transactions[b-1]].Portfolio
 	if portfolio.Invested().IsZero() {
-		return Performance{}
+		return Performance{TWR: twr}
 	}
 
 	var (
-		value     = portfolioValue(price, portfolio, end)
-		invested  = decimal.Zero
-		dayBefore = begin.AddDate(0, 0, -1)
+		value          = portfolioValue(price, portfolio, end)
+		invested       = decimal.Zero
+		grossInvested  = decimal.Zero
+		currencyEffect = decimal.Zero
+		dayBefore      = begin.AddDate(0, 0, -1)
 	)
-	for s, p := range portfolio {
+	for s, p := range portfolio.Stocks {
 		for _, b := range p.Batches {
+			start := b.Date
 			if b.Date.Before(begin) {
-				invested = invested.Add(price(s, dayBefore).Mul(b.Shares))
+				start = dayBefore
+				v := UnitPrice(price, s, dayBefore).Mul(b.Shares)
+				invested = invested.Add(v)
+				grossInvested = grossInvested.Add(v)
 			} else {
-				invested = invested.Add(b.PricePerShare.Mul(b.Shares))
+				invested = invested.Add(b.Invested())
+				grossInvested = grossInvested.Add(b.GrossInvested())
 			}
+			currencyEffect = currencyEffect.Add(conv.currencyEffect(s, UnitPrice(price, s, end).Mul(b.Shares), start, end))
 		}
 	}
 
+	profit := value.Sub(invested)
 	return Performance{
-		Return: Return(invested, value),
-		Profit: value.Sub(invested),
+		Return:         Return(invested, value),
+		Profit:         profit,
+		GrossReturn:    Return(grossInvested, value),
+		GrossProfit:    value.Sub(grossInvested),
+		PriceEffect:    profit.Sub(currencyEffect),
+		CurrencyEffect: currencyEffect,
+		TWR:            twr,
 	}
 }
 
+// CalculateTWR calculates the time-weighted return between begin and end by
+// chain-linking the returns of the sub-periods between the days with
+// transactions. Cash flows are assumed to happen at the end of the day, so
+// the return of a day starting without any stocks is based on the cost of
+// the buys.
+func CalculateTWR(ctx context.Context, price PriceFunc, transactions Transactions, stats map[*Transaction]Stats, begin, end time.Time) float64 {
+	a, b, ok := selectTransactions(transactions, begin, end)
+	if !ok {
+		return 0
+	}
+
+	var (
+		twr   = 1.0
+		value = decimal.Zero // value after the last sub-period
+	)
+	if a > 0 {
+		value = portfolioValue(price, stats[transactions[a-1]].Portfolio, begin.AddDate(0, 0, -1))
+	}
+
+	for i := a; i < b; {
+		var (
+			date    = transactions[i].Date
+			inflow  = decimal.Zero
+			outflow = decimal.Zero
+		)
+		for ; i < b && transactions[i].Date.Equal(date); i++ {
+			if t := transactions[i]; !t.Type.IsCash() {
+				if flow := t.CashFlow(); flow.IsNegative() {
+					inflow = inflow.Sub(flow)
+				} else {
+					outflow = outflow.Add(flow)
+				}
+			}
+		}
+
+		endValue := portfolioValue(price, stats[transactions[i-1]].Portfolio, date)
+		if value.IsPositive() {
+			twr *= Float64(endValue.Add(outflow).Sub(inflow).Div(value))
+		} else if inflow.IsPositive() {
+			twr *= Float64(endValue.Add(outflow).Div(inflow))
+		}
+		value = endValue
+	}
+
+	if b > 0 && value.IsPositive() {
+		twr *= Float64(portfolioValue(price, stats[transactions[b-1]].Portfolio, end).Div(value))
+	}
+
+	return twr - 1
+}
+
 func CalculateIRR(ctx context.Context, price PriceFunc, transactions Transactions, stats map[*Transaction]Stats, begin, end time.Time) float64 {
 	a, b, ok := selectTransactions(transactions, begin, end)
 	if !ok {
@@ -94,6 +178,9 @@ func CalculateIRR(ctx context.Context, price PriceFunc, transactions Transaction
 	}
 
 	for _, t := range transactions[a:b] {
+		if t.Type.IsCash() || t.CashFlow().IsZero() {
+			continue
+		}
 		values = append(values, xirr.Value{
 			Date:   t.Date,
 			Amount: Float64(t.Amount),
@@ -108,6 +195,61 @@ func CalculateIRR(ctx context.Context, price PriceFunc, transactions Transaction
 		})
 	}
 
+	if len(values) < 2 {
+		return math.NaN()
+	}
+	return irr(values)
+}
+
+// CalculateInvestorIRR calculates the money-weighted return of the depots with
+// a cash account between begin and end. In contrast to CalculateIRR, the cash
+// flows are the deposits into and withdrawals from the depots and the value
+// includes the cash balances.
+func CalculateInvestorIRR(ctx context.Context, price PriceFunc, transactions Transactions, stats map[*Transaction]Stats, begin, end time.Time) float64 {
+	a, b, ok := selectTransactions(transactions, begin, end)
+	if !ok || b == 0 {
+		return math.NaN()
+	}
+
+	var values []xirr.Value
+
+	if a > 0 {
+		beginningPortfolio := stats[transactions[a-1]].Portfolio
+		if value := beginningPortfolio.cashAccountsValue(price, begin.AddDate(0, 0, -1)); !value.IsZero() {
+			values = append(values, xirr.Value{
+				Date:   begin,
+				Amount: -Float64(value),
+			})
+		}
+	}
+
+	for _, t := range transactions[a:b] {
+		if !t.Type.IsExternal() {
+			continue
+		}
+		values = append(values, xirr.Value{
+			Date:   t.Date,
+			Amount: -Float64(t.Amount),
+		})
+	}
+
+	endingPortfolio := stats[transactions[b-1]].Portfolio
+	if value := endingPortfolio.cashAccountsValue(price, end); !value.IsZero() {
+		values = append(values, xirr.Value{
+			Date:   end,
+			Amount: Float64(value),
+		})
+	}
+
+	if len(values) < 2 {
+		return math.NaN()
+	}
+	return irr(values)
+}
+
+// irr returns the annualized internal rate of return of values, or the
+// non-annualized return if values span less than a year.
+func irr(values []xirr.Value) float64 {
 	r := xirr.XIRR(values, xirr.Guess(values))
 	if days := days(values[0].Date, values[len(values)-1].Date); days < 365 {
 		r = math.Pow(1+r, float64(days)/365) - 1
@@ -115,39 +257,29 @@ func CalculateIRR(ctx context.Context, price PriceFunc, transactions Transaction
 	return r
 }
 
+// selectTransactions returns the slice start of the first of the sorted
+// transactions on or after begin and the slice end of the last on or before
+// end, and false if all transactions are after end.
 func selectTransactions(transactions Transactions, begin, end time.Time) (int, int, bool) {
-	if len(transactions) == 0 {
-		return 0, 0, false
-	}
-	if transactions[0].Date.After(end) {
+	n := len(transactions)
+	if n == 0 || transactions[0].Date.After(end) {
 		return 0, 0, false
 	}
-	if transactions[len(transactions)-1].Date.Before(begin) {
-		return len(transactions), len(transactions), true
-	}
-	var (
-		a = -1 // slice start of first transaction >= begin
-		b = -1 // slice end of last transaction <= end
-	)
-	for i, t := range transactions {
-		if a == -1 && (t.Date.Equal(begin) || t.Date.After(begin)) {
-			a = i
-		}
-		if t.Date.Before(end) || t.Date.Equal(end) {
-			b = i + 1
-		}
-	}
-	if a == -1 || b == -1 {
-		panic("cf.selectTransactions: invalid a or b")
-	}
+	a := sort.Search(n, func(i int) bool {
+		return !transactions[i].Date.Before(begin)
+	})
+	b := sort.Search(n, func(i int) bool {
+		return transactions[i].Date.After(end)
+	})
 	return a, b, true
 }
 
 func portfolioValue(price PriceFunc, p Portfolio, date time.Time) decimal.Decimal {
 	value := decimal.Zero
-	for stock, pa := range p {
-		if pa.Shares().IsPositive() {
-			value = value.Add(price(stock, date).Mul(pa.Shares()))
+	for stock, pa := range p.Stocks {
+		// Short positions have a negative value.
+		if shares := pa.Shares(); !shares.IsZero() {
+			value = value.Add(UnitPrice(price, stock, date).Mul(shares))
 		}
 	}
 	return value
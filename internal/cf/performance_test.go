@@ -0,0 +1,137 @@
+package cf
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestCalculatePerformanceCurrencyEffect(t *testing.T) {
+	stock := &Stock{ISIN: "US88160R1014", Currency: "USD"}
+	stock.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2020, 1, 1),
+			Amount: decimal.RequireFromString("-1000"),
+			Shares: decimal.RequireFromString("-10"),
+			Stock:  stock,
+		},
+	}
+
+	var (
+		rate = func(from, to string, date time.Time) decimal.Decimal {
+			if date.Before(Date(2020, 6, 1)) {
+				return decimal.RequireFromString("1")
+			}
+			return decimal.RequireFromString("0.9")
+		}
+		price = func(stock *Stock, date time.Time) decimal.Decimal {
+			if date.Before(Date(2020, 6, 1)) {
+				return decimal.RequireFromString("100")
+			}
+			return decimal.RequireFromString("110")
+		}
+		conv = NewConverter("EUR", rate)
+	)
+
+	stocks, err := conv.Stocks([]*Stock{stock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transactions, stats, err := CalculateStats(stocks, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := CalculatePerformance(context.Background(), conv.PriceFunc(price), conv, transactions, stats, Date(2020, 1, 1), Date(2020, 6, 1))
+	testCases := map[string]struct {
+		Actual   decimal.Decimal
+		Expected string
+	}{
+		"profit":          {p.Profit, "-10"},
+		"price effect":    {p.PriceEffect, "100"},
+		"currency effect": {p.CurrencyEffect, "-110"},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if expected := decimal.RequireFromString(testCase.Expected); !testCase.Actual.Equal(expected) {
+				t.Fatalf("expected %s, got %s", expected, testCase.Actual)
+			}
+		})
+	}
+}
+
+func TestCalculateTWR(t *testing.T) {
+	stock := &Stock{ISIN: "US0378331005"}
+	stock.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2020, 1, 1),
+			Amount: decimal.RequireFromString("-1000"),
+			Shares: decimal.RequireFromString("-10"),
+			Stock:  stock,
+		},
+		{
+			Type:   Buy,
+			Date:   Date(2020, 1, 2),
+			Amount: decimal.RequireFromString("-1100"),
+			Shares: decimal.RequireFromString("-10"),
+			Stock:  stock,
+		},
+		{
+			Type:   Sell,
+			Date:   Date(2020, 1, 3),
+			Amount: decimal.RequireFromString("990"),
+			Shares: decimal.RequireFromString("10"),
+			Stock:  stock,
+		},
+	}
+
+	prices := map[time.Time]decimal.Decimal{
+		Date(2020, 1, 1): decimal.RequireFromString("100"),
+		Date(2020, 1, 2): decimal.RequireFromString("110"),
+		Date(2020, 1, 3): decimal.RequireFromString("99"),
+		Date(2020, 1, 4): decimal.RequireFromString("108.9"),
+	}
+	price := func(stock *Stock, date time.Time) decimal.Decimal {
+		return prices[date]
+	}
+
+	transactions, stats, err := CalculateStats([]*Stock{stock}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := map[string]struct {
+		Begin time.Time
+		End   time.Time
+		TWR   float64
+	}{
+		"overall": {
+			Begin: Date(2020, 1, 1),
+			End:   Date(2020, 1, 4),
+			TWR:   1.1*0.9*1.1 - 1,
+		},
+		"after first buy": {
+			Begin: Date(2020, 1, 2),
+			End:   Date(2020, 1, 3),
+			TWR:   1.1*0.9 - 1,
+		},
+		"without transactions": {
+			Begin: Date(2020, 1, 4),
+			End:   Date(2020, 1, 4),
+			TWR:   0.1,
+		},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			twr := CalculateTWR(context.Background(), price, transactions, stats, testCase.Begin, testCase.End)
+			if math.Abs(twr-testCase.TWR) > 1e-9 {
+				t.Fatalf("unexpected TWR: %f", twr)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package cf
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Period is a date range. A zero Begin means since the first transaction.
+type Period struct {
+	Name  string
+	Begin time.Time
+	End   time.Time
+}
+
+// ParsePeriod parses a named period ending on today. Valid names are
+// "overall", "ytd", "today", a number of months or years like "1m", "3m" or
+// "5y", and calendar years like "2019".
+func ParsePeriod(name string, today time.Time) (Period, error) {
+	name = strings.ToLower(name)
+	period := Period{
+		Name: name,
+		End:  today,
+	}
+
+	switch name {
+	case "overall":
+		return period, nil
+	case "ytd":
+		period.Begin = time.Date(today.Year(), 1, 1, 0, 0, 0, 0, today.Location())
+		return period, nil
+	case "today":
+		period.Begin = today
+		return period, nil
+	}
+
+	if year, err := strconv.Atoi(name); err == nil && len(name) == 4 {
+		period.Begin = time.Date(year, 1, 1, 0, 0, 0, 0, today.Location())
+		period.End = time.Date(year, 12, 31, 0, 0, 0, 0, today.Location())
+		if period.Begin.After(today) {
+			return Period{}, fmt.Errorf("cf: period %q is in the future", name)
+		}
+		if period.End.After(today) {
+			period.End = today
+		}
+		return period, nil
+	}
+
+	if len(name) >= 2 {
+		n, err := strconv.Atoi(name[:len(name)-1])
+		if err == nil && n > 0 {
+			switch name[len(name)-1] {
+			case 'm':
+				period.Begin = today.AddDate(0, -n, 0)
+				return period, nil
+			case 'y':
+				period.Begin = today.AddDate(-n, 0, 0)
+				return period, nil
+			}
+		}
+	}
+
+	return Period{}, fmt.Errorf("cf: invalid period %q", name)
+}
+
+type PeriodPerformance struct {
+	Period      Period
+	Performance Performance
+	IRR         float64
+}
+
+// CalculatePeriodPerformances calculates the performance and IRR for each of
+// periods. Periods starting before the first transaction start on the day
+// of the first transaction.
+func CalculatePeriodPerformances(ctx context.Context, price PriceFunc, conv *Converter, transactions Transactions, stats map[*Transaction]Stats, periods []Period) []PeriodPerformance {
+	performances := make([]PeriodPerformance, 0, len(periods))
+	for _, period := range periods {
+		if len(transactions) > 0 && period.Begin.Before(transactions[0].Date) {
+			period.Begin = transactions[0].Date
+		}
+		pp := PeriodPerformance{Period: period}
+		if len(stats) > 0 {
+			pp.Performance = CalculatePerformance(ctx, price, conv, transactions, stats, period.Begin, period.End)
+			pp.IRR = CalculateIRR(ctx, price, transactions, stats, period.Begin, period.End)
+		}
+		performances = append(performances, pp)
+	}
+	return performances
+}
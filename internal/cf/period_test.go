@@ -0,0 +1,45 @@
+package cf
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePeriod(t *testing.T) {
+	today := Date(2020, 12, 15)
+
+	testCases := map[string]struct {
+		Begin time.Time
+		End   time.Time
+		Err   bool
+	}{
+		"overall": {End: today},
+		"ytd":     {Begin: Date(2020, 1, 1), End: today},
+		"today":   {Begin: today, End: today},
+		"1m":      {Begin: Date(2020, 11, 15), End: today},
+		"6M":      {Begin: Date(2020, 6, 15), End: today},
+		"3y":      {Begin: Date(2017, 12, 15), End: today},
+		"2019":    {Begin: Date(2019, 1, 1), End: Date(2019, 12, 31)},
+		"2020":    {Begin: Date(2020, 1, 1), End: today},
+		"2021":    {Err: true},
+		"0m":      {Err: true},
+		"1w":      {Err: true},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			period, err := ParsePeriod(name, today)
+			if testCase.Err {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !period.Begin.Equal(testCase.Begin) || !period.End.Equal(testCase.End) {
+				t.Fatalf("unexpected period: %s - %s", period.Begin, period.End)
+			}
+		})
+	}
+}
@@ -7,93 +7,245 @@ import (
 	"github.com/shopspring/decimal"
 )
 
-func BuildPortfolio(stocks []*Stock) Portfolio {
-	p := Portfolio{}
-	for _, s := range stocks {
-		for _, t := range s.Transactions {
-			if t.Shares.IsPositive() {
-				p.RemoveShares(s, t)
-			} else if t.Shares.IsNegative() {
-				p.AddShares(s, t)
-			} else {
-				p.AddDividend(s, t)
-			}
-		}
+// PortfolioAt returns the portfolio after the last of transactions on or
+// before date.
+func PortfolioAt(transactions Transactions, stats map[*Transaction]Stats, date time.Time) Portfolio {
+	_, b, ok := selectTransactions(transactions, date, date)
+	if !ok || b == 0 {
+		return NewPortfolio(nil)
 	}
-	return p
+	return stats[transactions[b-1]].Portfolio
+}
+
+type Portfolio struct {
+	Stocks map[*Stock]*PortfolioStock
+
+	// Cash is the cash balance of each depot with a cash account.
+	Cash map[string]decimal.Decimal
+
+	// Depots are the depots with a Depot record by name.
+	Depots map[string]*Depot
+
+	// TaxAccounts are the tax accounts of the depots with a tax engine.
+	TaxAccounts map[string]TaxAccount
 }
 
-type Portfolio map[*Stock]*PortfolioStock
+// NewPortfolio returns an empty portfolio with a cash account for each of
+// depots.
+func NewPortfolio(depots []*Depot) Portfolio {
+	p := Portfolio{
+		Stocks:      map[*Stock]*PortfolioStock{},
+		Cash:        map[string]decimal.Decimal{},
+		Depots:      map[string]*Depot{},
+		TaxAccounts: map[string]TaxAccount{},
+	}
+	for _, d := range depots {
+		p.Cash[d.Name] = decimal.Zero
+		p.Depots[d.Name] = d
+	}
+	return p
+}
 
 func (p Portfolio) Invested() decimal.Decimal {
 	invested := decimal.Zero
-	for _, ps := range p {
+	for _, ps := range p.Stocks {
 		invested = invested.Add(ps.Invested())
 	}
 	return invested
 }
 
+func (p Portfolio) GrossInvested() decimal.Decimal {
+	invested := decimal.Zero
+	for _, ps := range p.Stocks {
+		invested = invested.Add(ps.GrossInvested())
+	}
+	return invested
+}
+
 func (p Portfolio) RealizedProfit() decimal.Decimal {
 	realizedProfit := decimal.Zero
-	for _, ps := range p {
+	for _, ps := range p.Stocks {
 		realizedProfit = realizedProfit.Add(ps.RealizedProfit)
 	}
 	return realizedProfit
 }
 
+func (p Portfolio) GrossRealizedProfit() decimal.Decimal {
+	realizedProfit := decimal.Zero
+	for _, ps := range p.Stocks {
+		realizedProfit = realizedProfit.Add(ps.GrossRealizedProfit)
+	}
+	return realizedProfit
+}
+
 func (p Portfolio) Dividends() decimal.Decimal {
 	dividends := decimal.Zero
-	for _, ps := range p {
+	for _, ps := range p.Stocks {
 		dividends = dividends.Add(ps.Dividends)
 	}
 	return dividends
 }
 
+func (p Portfolio) Fees() decimal.Decimal {
+	fees := decimal.Zero
+	for _, ps := range p.Stocks {
+		fees = fees.Add(ps.Fees)
+	}
+	return fees
+}
+
+func (p Portfolio) Taxes() decimal.Decimal {
+	taxes := decimal.Zero
+	for _, ps := range p.Stocks {
+		taxes = taxes.Add(ps.Taxes)
+	}
+	return taxes
+}
+
+func (p Portfolio) EstimatedTaxes() decimal.Decimal {
+	taxes := decimal.Zero
+	for _, ps := range p.Stocks {
+		taxes = taxes.Add(ps.EstimatedTaxes)
+	}
+	return taxes
+}
+
+func (p Portfolio) AfterTaxIncome() decimal.Decimal {
+	income := decimal.Zero
+	for _, ps := range p.Stocks {
+		income = income.Add(ps.AfterTaxIncome)
+	}
+	return income
+}
+
+// CashBalance returns the sum of the cash balances of all depots.
+func (p Portfolio) CashBalance() decimal.Decimal {
+	balance := decimal.Zero
+	for _, b := range p.Cash {
+		balance = balance.Add(b)
+	}
+	return balance
+}
+
+// Value returns the value of all stocks and cash on the given date.
+func (p Portfolio) Value(price PriceFunc, date time.Time) decimal.Decimal {
+	return portfolioValue(price, p, date).Add(p.CashBalance())
+}
+
+// credit adds amount to the cash balance of depot if it has a cash account.
+func (p Portfolio) credit(depot string, amount decimal.Decimal) {
+	if balance, ok := p.Cash[depot]; ok {
+		p.Cash[depot] = balance.Add(amount)
+	}
+}
+
+// cashAccountsValue returns the value of all stocks in depots with a cash
+// account plus their cash balances on the given date.
+func (p Portfolio) cashAccountsValue(price PriceFunc, date time.Time) decimal.Decimal {
+	value := p.CashBalance()
+	for s, ps := range p.Stocks {
+		for _, b := range ps.Batches {
+			if _, ok := p.Cash[b.Depot]; ok {
+				value = value.Add(UnitPrice(price, s, date).Mul(b.Shares))
+			}
+		}
+	}
+	return value
+}
+
 func (p Portfolio) AddShares(s *Stock, t *Transaction) {
-	ps, ok := p[s]
+	ps, ok := p.Stocks[s]
 	if !ok {
 		ps = &PortfolioStock{}
-		p[s] = ps
+		p.Stocks[s] = ps
 	}
 	ps.AddShares(t)
 }
 
-func (p Portfolio) RemoveShares(s *Stock, t *Transaction) (float64, decimal.Decimal, error) {
-	if p[s] == nil {
-		return 0, decimal.Zero, errors.New("cf: stock not in portfolio")
+func (p Portfolio) RemoveShares(s *Stock, t *Transaction) (SellStats, error) {
+	if p.Stocks[s] == nil {
+		return SellStats{}, newOversellError(t, t.Shares)
 	}
-	return p[s].RemoveShares(t)
+	return p.Stocks[s].RemoveShares(t, p.LotMethod(s, t.Depot))
 }
 
-func (p Portfolio) AddDividend(s *Stock, t *Transaction) (float64, error) {
-	if p[s] == nil {
-		return 0, errors.New("cf: stock not in portfolio")
+func (p Portfolio) AddDividend(s *Stock, t *Transaction) (DividendStats, error) {
+	if p.Stocks[s] == nil {
+		return DividendStats{}, errors.New("cf: stock not in portfolio")
+	}
+	return p.Stocks[s].AddDividend(t), nil
+}
+
+// Split applies the stock split t to all batches of stock s.
+func (p Portfolio) Split(s *Stock, t *Transaction) {
+	if p.Stocks[s] != nil {
+		p.Stocks[s].Split(t)
 	}
-	return p[s].AddDividend(t), nil
 }
 
 func (p Portfolio) Clone() Portfolio {
-	cloned := Portfolio{}
-	for s, ps := range p {
-		cloned[s] = ps.Clone()
+	cloned := Portfolio{
+		Stocks:      map[*Stock]*PortfolioStock{},
+		Cash:        map[string]decimal.Decimal{},
+		Depots:      p.Depots,
+		TaxAccounts: map[string]TaxAccount{},
+	}
+	for s, ps := range p.Stocks {
+		cloned.Stocks[s] = ps.Clone()
+	}
+	for depot, balance := range p.Cash {
+		cloned.Cash[depot] = balance
+	}
+	for depot, account := range p.TaxAccounts {
+		cloned.TaxAccounts[depot] = account
 	}
 	return cloned
 }
 
 type PortfolioStock struct {
-	Batches        []PortfolioStockBatch
-	RealizedProfit decimal.Decimal
-	Dividends      decimal.Decimal
+	Batches []PortfolioStockBatch
+
+	// Dividends are the dividends, or the coupons and accrued interest of
+	// bonds.
+	Dividends decimal.Decimal
+
+	RealizedProfit      decimal.Decimal
+	GrossRealizedProfit decimal.Decimal
+	Fees                decimal.Decimal
+	Taxes               decimal.Decimal
+
+	// EstimatedTaxes are the taxes on sells and dividends estimated by the
+	// tax engines of the depots, or the recorded taxes without tax engine.
+	// AfterTaxIncome is the realized profit plus dividends after those
+	// taxes.
+	EstimatedTaxes decimal.Decimal
+	AfterTaxIncome decimal.Decimal
 }
 
 func (ps *PortfolioStock) Clone() *PortfolioStock {
 	return &PortfolioStock{
-		Batches:        append(ps.Batches[:0:0], ps.Batches...),
-		RealizedProfit: ps.RealizedProfit,
-		Dividends:      ps.Dividends,
+		Batches:             append(ps.Batches[:0:0], ps.Batches...),
+		RealizedProfit:      ps.RealizedProfit,
+		GrossRealizedProfit: ps.GrossRealizedProfit,
+		Dividends:           ps.Dividends,
+		Fees:                ps.Fees,
+		Taxes:               ps.Taxes,
+		EstimatedTaxes:      ps.EstimatedTaxes,
+		AfterTaxIncome:      ps.AfterTaxIncome,
 	}
 }
 
+func (ps *PortfolioStock) addTax(tax, afterTaxIncome decimal.Decimal) {
+	ps.EstimatedTaxes = ps.EstimatedTaxes.Add(tax)
+	ps.AfterTaxIncome = ps.AfterTaxIncome.Add(afterTaxIncome)
+}
+
+// addAccruedInterest adds the interest accrued on the bond sold or bought by
+// t to the income of the stock.
+func (ps *PortfolioStock) addAccruedInterest(t *Transaction) {
+	ps.Dividends = ps.Dividends.Add(t.AccruedInterest)
+}
+
 func (ps *PortfolioStock) Shares() decimal.Decimal {
 	shares := decimal.Zero
 	for _, b := range ps.Batches {
@@ -110,6 +262,14 @@ func (ps *PortfolioStock) Invested() decimal.Decimal {
 	return invested
 }
 
+func (ps *PortfolioStock) GrossInvested() decimal.Decimal {
+	invested := decimal.Zero
+	for _, b := range ps.Batches {
+		invested = invested.Add(b.GrossInvested())
+	}
+	return invested
+}
+
 func (ps *PortfolioStock) PricePerShare() decimal.Decimal {
 	shares := ps.Shares()
 	if shares.IsZero() {
@@ -119,73 +279,212 @@ func (ps *PortfolioStock) PricePerShare() decimal.Decimal {
 }
 
 func (ps *PortfolioStock) AddShares(t *Transaction) {
+	ps.addBatch(t, t.Shares.Abs())
+}
+
+// addBatch adds a batch of shares opened by t.
+func (ps *PortfolioStock) addBatch(t *Transaction, shares decimal.Decimal) {
+	lot := t.Lot
+	if lot == "" {
+		lot = ps.newLot(t.Date)
+	}
 	ps.Batches = append(ps.Batches, PortfolioStockBatch{
+		Lot:           lot,
 		Depot:         t.Depot,
 		Date:          t.Date,
-		Shares:        t.Shares.Abs(),
+		Shares:        shares,
 		PricePerShare: t.Amount.Div(t.Shares),
+		Fees:          t.Fees,
+		Taxes:         t.Taxes,
 		Transactions:  Transactions{t},
 	})
+	ps.Fees = ps.Fees.Add(t.Fees)
+	ps.Taxes = ps.Taxes.Add(t.Taxes)
 }
 
-func (ps *PortfolioStock) RemoveShares(t *Transaction) (float64, decimal.Decimal, error) {
+// RemoveShares removes the shares sold by t from the batches in the order
+// given by method. Only sells with SpecificLot may name a lot.
+func (ps *PortfolioStock) RemoveShares(t *Transaction, method LotMethod) (SellStats, error) {
+	if err := checkLot(t, method); err != nil {
+		return SellStats{}, err
+	}
+	if method == AverageCost {
+		ps.average(t.Depot)
+	}
+
+	lots := ps.lots(t, method)
+	available := decimal.Zero
+	for _, i := range lots {
+		available = available.Add(ps.Batches[i].Shares)
+	}
+	if available.LessThan(t.Shares) {
+		return SellStats{}, newOversellError(t, t.Shares.Sub(available))
+	}
+
 	var (
-		toRemove = t.Shares
-		invested   = decimal.Zero
+		toRemove      = t.Shares
+		invested      = decimal.Zero
+		grossInvested = decimal.Zero
+		removed       = map[int]bool{}
+		sold          []SoldLot
 	)
 
-outer:
-	for toRemove.IsPositive() {
-		if len(ps.Batches) == 0 {
-			return 0, decimal.Zero, errors.New("cf: invalid transaction: no batches left")
+	for _, i := range lots {
+		if !toRemove.IsPositive() {
+			break
 		}
 
-		for i, b := range ps.Batches {
-			if b.Depot != t.Depot {
-				continue
-			}
+		b := ps.Batches[i]
+		if toRemove.GreaterThanOrEqual(b.Shares) {
+			toRemove = toRemove.Sub(b.Shares)
+			invested = invested.Add(b.Invested())
+			grossInvested = grossInvested.Add(b.GrossInvested())
+			removed[i] = true
+			sold = append(sold, b.sold(t))
+		} else {
+			part := b.part(toRemove)
+			invested = invested.Add(part.Invested())
+			grossInvested = grossInvested.Add(part.GrossInvested())
+			sold = append(sold, part.sold(t))
+			ps.Batches[i].Shares = b.Shares.Sub(part.Shares)
+			ps.Batches[i].Fees = b.Fees.Sub(part.Fees)
+			ps.Batches[i].Taxes = b.Taxes.Sub(part.Taxes)
+			ps.Batches[i].Transactions = append(b.Transactions, &Transaction{
+				Type:   t.Type,
+				Date:   t.Date,
+				Amount: t.Amount.Div(t.Shares).Mul(toRemove),
+				Shares: toRemove,
+				Fees:   t.Fees.Mul(toRemove).Div(t.Shares),
+				Taxes:  t.Taxes.Mul(toRemove).Div(t.Shares),
+				Depot:  t.Depot,
+				Stock:  t.Stock,
+			})
+			toRemove = decimal.Zero
+		}
+	}
 
-			if toRemove.GreaterThanOrEqual(b.Shares) {
-				toRemove = toRemove.Sub(b.Shares)
-				invested = invested.Add(b.Shares.Mul(b.PricePerShare))
-				ps.Batches = append(ps.Batches[0:i], ps.Batches[i+1:]...)
-			} else {
-				invested = invested.Add(b.PricePerShare.Mul(toRemove))
-				ps.Batches[i].Shares = ps.Batches[i].Shares.Sub(toRemove)
-				ps.Batches[i].Transactions = append(ps.Batches[i].Transactions, &Transaction{
-					Date:   t.Date,
-					Amount: t.Amount.Div(t.Shares).Mul(toRemove),
-					Shares: toRemove,
-					Depot:  t.Depot,
-					Stock:  t.Stock,
-				})
-				toRemove = decimal.Zero
+	if len(removed) > 0 {
+		batches := ps.Batches[:0:0]
+		for i, b := range ps.Batches {
+			if !removed[i] {
+				batches = append(batches, b)
 			}
-
-			continue outer
 		}
-
-		return 0, decimal.Zero, errors.New("cf: invalid transaction: no batches left")
+		ps.Batches = batches
 	}
 
-	profit := t.Amount.Sub(invested)
+	var (
+		grossAmount = t.GrossAmount()
+		profit      = t.Amount.Sub(invested)
+		grossProfit = grossAmount.Sub(grossInvested)
+	)
 	ps.RealizedProfit = ps.RealizedProfit.Add(profit)
-	return Return(invested, t.Amount), profit, nil
+	ps.GrossRealizedProfit = ps.GrossRealizedProfit.Add(grossProfit)
+	ps.Fees = ps.Fees.Add(t.Fees)
+	ps.Taxes = ps.Taxes.Add(t.Taxes)
+
+	return SellStats{
+		Return:        Return(invested, t.Amount),
+		Profit:        profit,
+		GrossReturn:   Return(grossInvested, grossAmount),
+		GrossProfit:   grossProfit,
+		PricePerShare: t.Amount.Div(t.Shares),
+		Lots:          sold,
+	}, nil
 }
 
-func (ps *PortfolioStock) AddDividend(t *Transaction) float64 {
+func (ps *PortfolioStock) AddDividend(t *Transaction) DividendStats {
 	ps.Dividends = ps.Dividends.Add(t.Amount)
-	return Return(ps.Invested(), t.Amount)
+	ps.Fees = ps.Fees.Add(t.Fees)
+	ps.Taxes = ps.Taxes.Add(t.Taxes)
+	return DividendStats{
+		Return:      Return(ps.Invested(), t.Amount),
+		GrossReturn: Return(ps.GrossInvested(), t.GrossAmount()),
+	}
+}
+
+// Reinvest adds the shares bought by reinvesting the dividend t as a new batch
+// with the dividend as its cost basis.
+func (ps *PortfolioStock) Reinvest(t *Transaction) {
+	ps.AddShares(&Transaction{
+		Type:   Buy,
+		Date:   t.Date,
+		Amount: t.Amount.Neg(),
+		Shares: t.ReinvestedShares.Neg(),
+		Depot:  t.Depot,
+		Lot:    t.Lot,
+		Stock:  t.Stock,
+	})
+}
+
+// Split rescales the shares and prices of all batches by the split ratio.
+func (ps *PortfolioStock) Split(t *Transaction) {
+	for i, b := range ps.Batches {
+		ps.Batches[i].Shares = t.Ratio.Shares(b.Shares)
+		ps.Batches[i].PricePerShare = t.Ratio.Price(b.PricePerShare)
+		ps.Batches[i].Transactions = append(b.Transactions, t)
+	}
 }
 
 type PortfolioStockBatch struct {
-	Depot         string
-	Date          time.Time
+	Lot   string
+	Depot string
+	Date  time.Time
+
+	// Shares are negative for short batches.
 	Shares        decimal.Decimal
 	PricePerShare decimal.Decimal
+	Fees          decimal.Decimal
+	Taxes         decimal.Decimal
 	Transactions  Transactions
 }
 
+// Invested returns the cost basis of the batch including fees and taxes.
 func (b PortfolioStockBatch) Invested() decimal.Decimal {
 	return b.Shares.Mul(b.PricePerShare)
 }
+
+// GrossInvested returns the cost basis of the batch excluding fees and taxes.
+func (b PortfolioStockBatch) GrossInvested() decimal.Decimal {
+	return b.Invested().Sub(b.Fees).Sub(b.Taxes)
+}
+
+// GrossPricePerShare returns the price per share excluding fees and taxes.
+func (b PortfolioStockBatch) GrossPricePerShare() decimal.Decimal {
+	if b.Shares.IsZero() {
+		return decimal.Zero
+	}
+	return b.GrossInvested().Div(b.Shares)
+}
+
+// part returns the given number of shares of the batch with fees and taxes
+// allocated proportionally.
+func (b PortfolioStockBatch) part(shares decimal.Decimal) PortfolioStockBatch {
+	part := b
+	part.Shares = shares
+	part.Fees = b.Fees.Mul(shares).Div(b.Shares)
+	part.Taxes = b.Taxes.Mul(shares).Div(b.Shares)
+	return part
+}
+
+// sold returns the batch as a lot sold by t, which may sell more shares than
+// the batch has.
+func (b PortfolioStockBatch) sold(t *Transaction) SoldLot {
+	var (
+		share    = func(d decimal.Decimal) decimal.Decimal { return d.Mul(b.Shares).Div(t.Shares) }
+		proceeds = share(t.Amount)
+	)
+	return SoldLot{
+		Lot:            b.Lot,
+		Acquired:       b.Date,
+		Sold:           t.Date,
+		Shares:         b.Shares,
+		Proceeds:       proceeds,
+		GrossProceeds:  share(t.GrossAmount()),
+		CostBasis:      b.Invested(),
+		GrossCostBasis: b.GrossInvested(),
+		Fees:           b.Fees.Add(share(t.Fees)),
+		Taxes:          b.Taxes.Add(share(t.Taxes)),
+		Profit:         proceeds.Sub(b.Invested()),
+	}
+}
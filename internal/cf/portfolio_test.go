@@ -0,0 +1,463 @@
+package cf
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestPortfolioStockFeesAndTaxes(t *testing.T) {
+	var (
+		stock = &Stock{ISIN: "US88160R1014"}
+		ps    = &PortfolioStock{}
+	)
+
+	ps.AddShares(&Transaction{
+		Date:   Date(2020, 1, 1),
+		Amount: decimal.RequireFromString("-1010"),
+		Shares: decimal.RequireFromString("-10"),
+		Fees:   decimal.RequireFromString("10"),
+		Stock:  stock,
+	})
+
+	sell, err := ps.RemoveShares(&Transaction{
+		Date:   Date(2020, 6, 1),
+		Amount: decimal.RequireFromString("580"),
+		Shares: decimal.RequireFromString("5"),
+		Fees:   decimal.RequireFromString("5"),
+		Taxes:  decimal.RequireFromString("15"),
+		Stock:  stock,
+	}, FIFO)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := map[string]struct {
+		Actual   decimal.Decimal
+		Expected string
+	}{
+		"profit":                {sell.Profit, "75"},
+		"gross profit":          {sell.GrossProfit, "100"},
+		"realized profit":       {ps.RealizedProfit, "75"},
+		"gross realized profit": {ps.GrossRealizedProfit, "100"},
+		"invested":              {ps.Invested(), "505"},
+		"gross invested":        {ps.GrossInvested(), "500"},
+		"fees":                  {ps.Fees, "15"},
+		"taxes":                 {ps.Taxes, "15"},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if expected := decimal.RequireFromString(testCase.Expected); !testCase.Actual.Equal(expected) {
+				t.Fatalf("expected %s, got %s", expected, testCase.Actual)
+			}
+		})
+	}
+}
+
+func TestCalculateStatsSplit(t *testing.T) {
+	stock := &Stock{ISIN: "US88160R1014"}
+	stock.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2017, 10, 6),
+			Amount: decimal.RequireFromString("-3925"),
+			Shares: decimal.RequireFromString("-25"),
+			Stock:  stock,
+		},
+		{
+			Type:  Split,
+			Date:  Date(2020, 8, 31),
+			Ratio: Ratio{New: decimal.NewFromInt(5), Old: decimal.NewFromInt(1)},
+			Stock: stock,
+		},
+		{
+			Type:   Sell,
+			Date:   Date(2020, 10, 15),
+			Amount: decimal.RequireFromString("4500"),
+			Shares: decimal.RequireFromString("10"),
+			Stock:  stock,
+		},
+	}
+
+	transactions, stats, err := CalculateStats([]*Stock{stock}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		buy  = stats[transactions[0]]
+		sell = stats[transactions[2]]
+		ps   = sell.Portfolio.Stocks[stock]
+	)
+	if expected := decimal.RequireFromString("157"); !buy.Buy.PricePerShare.Equal(expected) {
+		t.Errorf("unexpected price per share: %s", buy.Buy.PricePerShare)
+	}
+	if expected := decimal.RequireFromString("31.4"); !buy.Buy.AdjustedPricePerShare.Equal(expected) {
+		t.Errorf("unexpected adjusted price per share: %s", buy.Buy.AdjustedPricePerShare)
+	}
+	if expected := decimal.RequireFromString("4186"); !sell.Sell.Profit.Equal(expected) {
+		t.Errorf("unexpected profit: %s", sell.Sell.Profit)
+	}
+	if expected := decimal.RequireFromString("115"); !ps.Shares().Equal(expected) {
+		t.Errorf("unexpected shares: %s", ps.Shares())
+	}
+	if expected := decimal.RequireFromString("3611"); !ps.Invested().Equal(expected) {
+		t.Errorf("unexpected invested: %s", ps.Invested())
+	}
+}
+
+func TestCashAccount(t *testing.T) {
+	stock := &Stock{ISIN: "US0378331005"}
+	stock.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2020, 1, 2),
+			Amount: decimal.RequireFromString("-1000"),
+			Shares: decimal.RequireFromString("-10"),
+			Depot:  "comdirect",
+			Stock:  stock,
+		},
+		{
+			Type:   Dividend,
+			Date:   Date(2020, 6, 1),
+			Amount: decimal.RequireFromString("50"),
+			Depot:  "comdirect",
+			Stock:  stock,
+		},
+	}
+	depot := &Depot{
+		Name: "comdirect",
+		Transactions: Transactions{
+			{
+				Type:   Deposit,
+				Date:   Date(2020, 1, 1),
+				Amount: decimal.RequireFromString("10000"),
+				Depot:  "comdirect",
+			},
+			{
+				Type:   Fee,
+				Date:   Date(2020, 12, 31),
+				Amount: decimal.RequireFromString("-50"),
+				Depot:  "comdirect",
+			},
+		},
+	}
+
+	transactions, stats, err := CalculateStats([]*Stock{stock}, []*Depot{depot})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	portfolio := stats[transactions[len(transactions)-1]].Portfolio
+	if expected := decimal.RequireFromString("9000"); !portfolio.CashBalance().Equal(expected) {
+		t.Fatalf("unexpected cash balance: %s", portfolio.CashBalance())
+	}
+
+	price := func(stock *Stock, date time.Time) decimal.Decimal {
+		return decimal.RequireFromString("200")
+	}
+	irr := CalculateInvestorIRR(context.Background(), price, transactions, stats, Date(2020, 1, 1), Date(2020, 12, 31))
+	if math.Abs(irr-0.1) > 1e-6 {
+		t.Fatalf("unexpected investor IRR: %f", irr)
+	}
+}
+
+func TestReinvestedDividend(t *testing.T) {
+	stock := &Stock{ISIN: "US0378331005"}
+	stock.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2020, 1, 2),
+			Amount: decimal.RequireFromString("-1000"),
+			Shares: decimal.RequireFromString("-10"),
+			Depot:  "comdirect",
+			Stock:  stock,
+		},
+		{
+			Type:             Dividend,
+			Date:             Date(2020, 7, 1),
+			Amount:           decimal.RequireFromString("50"),
+			ReinvestedShares: decimal.RequireFromString("0.5"),
+			Depot:            "comdirect",
+			Stock:            stock,
+		},
+	}
+	depot := &Depot{
+		Name: "comdirect",
+		Transactions: Transactions{
+			{
+				Type:   Deposit,
+				Date:   Date(2020, 1, 1),
+				Amount: decimal.RequireFromString("1000"),
+				Depot:  "comdirect",
+			},
+		},
+	}
+
+	transactions, stats, err := CalculateStats([]*Stock{stock}, []*Depot{depot})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		portfolio = stats[transactions[len(transactions)-1]].Portfolio
+		ps        = portfolio.Stocks[stock]
+	)
+
+	testCases := map[string]struct {
+		Actual   decimal.Decimal
+		Expected string
+	}{
+		"cash balance": {portfolio.CashBalance(), "0"},
+		"shares":       {ps.Shares(), "10.5"},
+		"invested":     {ps.Invested(), "1050"},
+		"dividends":    {ps.Dividends, "50"},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if expected := decimal.RequireFromString(testCase.Expected); !testCase.Actual.Equal(expected) {
+				t.Fatalf("expected %s, got %s", expected, testCase.Actual)
+			}
+		})
+	}
+
+	if n := len(ps.Batches); n != 2 {
+		t.Fatalf("expected 2 batches, got %d", n)
+	}
+
+	price := func(stock *Stock, date time.Time) decimal.Decimal {
+		return decimal.RequireFromString("100")
+	}
+	// The dividend is only earned once, by the value of the new shares.
+	twr := CalculateTWR(context.Background(), price, transactions, stats, Date(2020, 1, 1), Date(2020, 12, 31))
+	if math.Abs(twr-0.05) > 1e-9 {
+		t.Fatalf("unexpected TWR: %f", twr)
+	}
+	irr := CalculateIRR(context.Background(), price, transactions, stats, Date(2020, 1, 1), Date(2020, 12, 31))
+	if math.Abs(irr-0.05) > 1e-3 {
+		t.Fatalf("unexpected IRR: %f", irr)
+	}
+}
+
+func TestPortfolioStockLotMethods(t *testing.T) {
+	testCases := map[string]struct {
+		Method LotMethod
+		Lot    string
+		Profit string
+	}{
+		"fifo": {
+			Method: FIFO,
+			Profit: "500",
+		},
+		"lifo": {
+			Method: LIFO,
+			Profit: "300",
+		},
+		"hifo": {
+			Method: HIFO,
+			Profit: "200",
+		},
+		"average": {
+			Method: AverageCost,
+			Profit: "333.33",
+		},
+		"specific": {
+			Method: SpecificLot,
+			Lot:    "b",
+			Profit: "200",
+		},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ps := &PortfolioStock{}
+			for _, tx := range []*Transaction{
+				{Date: Date(2020, 1, 1), Amount: decimal.RequireFromString("-500"), Shares: decimal.RequireFromString("-5"), Lot: "a"},
+				{Date: Date(2020, 2, 1), Amount: decimal.RequireFromString("-800"), Shares: decimal.RequireFromString("-5"), Lot: "b"},
+				{Date: Date(2020, 3, 1), Amount: decimal.RequireFromString("-700"), Shares: decimal.RequireFromString("-5"), Lot: "c"},
+			} {
+				ps.AddShares(tx)
+			}
+
+			sell, err := ps.RemoveShares(&Transaction{
+				Date:   Date(2020, 4, 1),
+				Amount: decimal.RequireFromString("1000"),
+				Shares: decimal.RequireFromString("5"),
+				Lot:    testCase.Lot,
+			}, testCase.Method)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := decimal.RequireFromString(testCase.Profit); !sell.Profit.Round(2).Equal(expected) {
+				t.Errorf("unexpected profit: %s", sell.Profit)
+			}
+
+			_, err = ps.RemoveShares(&Transaction{
+				Date:   Date(2020, 4, 2),
+				Amount: decimal.RequireFromString("1000"),
+				Shares: decimal.RequireFromString("5"),
+				Lot:    testCase.Lot,
+			}, testCase.Method)
+			if testCase.Lot != "" && err == nil {
+				t.Errorf("expected error selling closed lot")
+			}
+		})
+	}
+}
+
+func TestPortfolioStockNamedLots(t *testing.T) {
+	ps := &PortfolioStock{}
+	for _, tx := range []*Transaction{
+		{Date: Date(2020, 1, 1), Amount: decimal.RequireFromString("-500"), Shares: decimal.RequireFromString("-5")},
+		{Date: Date(2020, 1, 1), Amount: decimal.RequireFromString("-800"), Shares: decimal.RequireFromString("-5")},
+	} {
+		ps.AddShares(tx)
+	}
+	if a, b := ps.Batches[0].Lot, ps.Batches[1].Lot; a != "2020-01-01" || b != "2020-01-01-2" {
+		t.Fatalf("expected unique lots 2020-01-01 and 2020-01-01-2, got %s and %s", a, b)
+	}
+
+	sell := &Transaction{
+		Date:   Date(2020, 4, 1),
+		Amount: decimal.RequireFromString("1000"),
+		Shares: decimal.RequireFromString("5"),
+		Lot:    "2020-01-01-2",
+	}
+	if _, err := ps.Clone().RemoveShares(sell, FIFO); err == nil {
+		t.Fatal("expected error for a lot with FIFO")
+	}
+	stats, err := ps.RemoveShares(sell, SpecificLot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := decimal.RequireFromString("200"); !stats.Profit.Equal(expected) {
+		t.Fatalf("expected profit %s of the second lot only, got %s", expected, stats.Profit)
+	}
+}
+
+func TestPortfolioLotMethod(t *testing.T) {
+	var (
+		stock     = &Stock{ISIN: "US0378331005", LotMethod: HIFO}
+		portfolio = NewPortfolio([]*Depot{{Name: "comdirect", LotMethod: FIFO}, {Name: "ibkr"}})
+	)
+	if method := portfolio.LotMethod(stock, "comdirect"); method != FIFO {
+		t.Fatalf("expected lot method of the depot, got %s", method)
+	}
+	if method := portfolio.LotMethod(stock, "ibkr"); method != HIFO {
+		t.Fatalf("expected lot method of the stock, got %s", method)
+	}
+	if method := portfolio.LotMethod(&Stock{}, "ibkr"); method != FIFO {
+		t.Fatalf("expected FIFO, got %s", method)
+	}
+}
+
+func TestCalculateStockStats(t *testing.T) {
+	stock := &Stock{ISIN: "US0378331005"}
+	stock.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2020, 1, 1),
+			Amount: decimal.RequireFromString("-500"),
+			Shares: decimal.RequireFromString("-5"),
+			Depot:  "comdirect",
+			Stock:  stock,
+		},
+		{
+			Type:   Buy,
+			Date:   Date(2020, 2, 1),
+			Amount: decimal.RequireFromString("-800"),
+			Shares: decimal.RequireFromString("-5"),
+			Depot:  "comdirect",
+			Stock:  stock,
+		},
+		{
+			Type:   Sell,
+			Date:   Date(2020, 3, 1),
+			Amount: decimal.RequireFromString("1000"),
+			Shares: decimal.RequireFromString("5"),
+			Depot:  "comdirect",
+			Stock:  stock,
+		},
+	}
+	depot := &Depot{
+		Name:      "comdirect",
+		LotMethod: LIFO,
+		Transactions: Transactions{
+			{
+				Type:   Deposit,
+				Date:   Date(2019, 12, 1),
+				Amount: decimal.RequireFromString("10000"),
+				Depot:  "comdirect",
+			},
+		},
+	}
+
+	transactions, stats, err := CalculateStockStats([]*Stock{stock}, []*Depot{depot})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transactions) != 3 {
+		t.Fatalf("expected 3 transactions, got %d", len(transactions))
+	}
+
+	sell := stats[stock.Transactions[2]]
+	if expected := decimal.RequireFromString("200"); !sell.Sell.Profit.Equal(expected) {
+		t.Errorf("unexpected profit: %s", sell.Sell.Profit)
+	}
+	if !sell.Portfolio.CashBalance().IsZero() {
+		t.Errorf("unexpected cash balance: %s", sell.Portfolio.CashBalance())
+	}
+}
+
+func TestPortfolioAt(t *testing.T) {
+	stock := &Stock{ISIN: "US88160R1014"}
+	stock.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2019, 3, 1),
+			Amount: decimal.RequireFromString("-1000"),
+			Shares: decimal.RequireFromString("-10"),
+			Stock:  stock,
+		},
+		{
+			Type:   Buy,
+			Date:   Date(2020, 2, 1),
+			Amount: decimal.RequireFromString("-2000"),
+			Shares: decimal.RequireFromString("-10"),
+			Stock:  stock,
+		},
+	}
+
+	transactions, stats, err := CalculateStats([]*Stock{stock}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := map[string]struct {
+		Date     time.Time
+		Shares   string
+		Invested string
+	}{
+		"before first": {Date(2019, 1, 1), "0", "0"},
+		"on first":     {Date(2019, 3, 1), "10", "1000"},
+		"year end":     {Date(2019, 12, 31), "10", "1000"},
+		"after last":   {Date(2020, 12, 31), "20", "3000"},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			portfolio := PortfolioAt(transactions, stats, testCase.Date)
+			shares := decimal.Zero
+			if ps := portfolio.Stocks[stock]; ps != nil {
+				shares = ps.Shares()
+			}
+			if expected := decimal.RequireFromString(testCase.Shares); !shares.Equal(expected) {
+				t.Fatalf("expected %s shares, got %s", expected, shares)
+			}
+			if expected := decimal.RequireFromString(testCase.Invested); !portfolio.Invested().Equal(expected) {
+				t.Fatalf("expected %s invested, got %s", expected, portfolio.Invested())
+			}
+		})
+	}
+}
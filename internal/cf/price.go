@@ -14,11 +14,23 @@ type Price struct {
 	Price decimal.Decimal
 }
 
+// PriceProvider provides the prices of stocks. History returns the prices
+// since the given date, or of a default period of the provider if since is
+// zero.
 type PriceProvider interface {
-	History(ctx context.Context, stock *Stock) ([]Price, error)
+	History(ctx context.Context, stock *Stock, since time.Time) ([]Price, error)
 	Current(ctx context.Context, stock *Stock) (decimal.Decimal, error)
 }
 
+// PairProvider provides the prices of pair symbols like BTC-EUR, which quote
+// a symbol in a currency; see Stock.Pair.
+type PairProvider interface {
+	History(ctx context.Context, pair string, since time.Time) ([]Price, error)
+	Current(ctx context.Context, pair string) (decimal.Decimal, error)
+}
+
+// PriceFunc returns the price of a stock on a date. Calculations expect prices
+// in the same currency as the transaction amounts; see Converter.PriceFunc.
 type PriceFunc func(stock *Stock, date time.Time) decimal.Decimal
 
 func zeroPriceFunc(stock *Stock, date time.Time) decimal.Decimal {
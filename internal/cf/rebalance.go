@@ -0,0 +1,197 @@
+package cf
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Targets are the target weights of the portfolio by dimension, like 0.6 for
+// "equity" and 0.4 for "bond" by AssetClass.
+type Targets map[Dimension]map[string]decimal.Decimal
+
+// RebalanceOptions control the trades suggested by CalculateRebalance. Cash
+// is new cash to invest. With BuyOnly, only Cash is invested and nothing is
+// sold. Trades of less than MinTrade are dropped.
+type RebalanceOptions struct {
+	Cash     decimal.Decimal
+	BuyOnly  bool
+	MinTrade decimal.Decimal
+}
+
+// RebalanceTarget compares the current value and weight of a key with its
+// target. Drift is the current minus the target weight.
+type RebalanceTarget struct {
+	Key         string
+	Value       decimal.Decimal
+	Weight      float64
+	TargetValue decimal.Decimal
+	Target      float64
+	Drift       float64
+}
+
+// RebalanceTrade is a suggested buy or sell. Shares and Amount are positive
+// for both.
+type RebalanceTrade struct {
+	Stock  *Stock
+	Type   TransactionType
+	Shares decimal.Decimal
+	Amount decimal.Decimal
+}
+
+// Rebalance is the drift of a portfolio from its targets in a dimension and
+// the trades restoring them. Unallocated is the amount to buy of keys
+// without any stock in the portfolio.
+type Rebalance struct {
+	Dimension   Dimension
+	Total       decimal.Decimal
+	Cash        decimal.Decimal
+	Targets     []RebalanceTarget
+	Trades      []RebalanceTrade
+	Unallocated decimal.Decimal
+}
+
+// CalculateRebalance compares the allocation of portfolio on date by
+// dimension d with targets and suggests the trades restoring the targets.
+// Keys without a target have a target weight of zero. Cash balances are
+// invested unless the asset class Cash has a target. The amount of a key is
+// distributed over the stocks of the key in proportion to their value, which
+// is only approximate for stocks with look-through weights or several tags.
+// Whole shares are traded of stocks held in whole shares.
+func CalculateRebalance(price PriceFunc, portfolio Portfolio, date time.Time, d Dimension, targets map[string]decimal.Decimal, options RebalanceOptions) Rebalance {
+	total, entries := CalculateAllocation(price, portfolio, date, d)
+	rebalance := Rebalance{
+		Dimension: d,
+		Total:     total,
+		Cash:      options.Cash,
+	}
+
+	current := map[string]decimal.Decimal{}
+	for _, e := range entries {
+		current[e.Key] = e.Value
+	}
+	for key := range targets {
+		if _, ok := current[key]; !ok {
+			current[key] = decimal.Zero
+		}
+	}
+
+	investable := total.Add(options.Cash)
+	amounts := map[string]decimal.Decimal{}
+	for key, value := range current {
+		target := targets[key]
+		targetValue := target.Mul(investable)
+		t := RebalanceTarget{
+			Key:         key,
+			Value:       value,
+			TargetValue: targetValue,
+			Target:      Float64(target),
+		}
+		if !total.IsZero() {
+			t.Weight = Float64(value.Div(total))
+		}
+		t.Drift = t.Weight - t.Target
+		rebalance.Targets = append(rebalance.Targets, t)
+
+		if d == AssetClass && key == Cash {
+			continue
+		}
+		amount := targetValue.Sub(value)
+		if options.BuyOnly && !amount.IsPositive() {
+			continue
+		}
+		if !amount.IsZero() {
+			amounts[key] = amount
+		}
+	}
+	sort.Slice(rebalance.Targets, func(i, j int) bool {
+		a, b := rebalance.Targets[i], rebalance.Targets[j]
+		if da, db := math.Abs(a.Drift), math.Abs(b.Drift); da != db {
+			return da > db
+		}
+		return a.Key < b.Key
+	})
+
+	if options.BuyOnly {
+		shortfall := decimal.Zero
+		for _, amount := range amounts {
+			shortfall = shortfall.Add(amount)
+		}
+		if shortfall.GreaterThan(options.Cash) {
+			for key, amount := range amounts {
+				amounts[key] = amount.Mul(options.Cash).Div(shortfall)
+			}
+		}
+	}
+
+	var (
+		prices = map[*Stock]decimal.Decimal{}
+		trades = map[*Stock]decimal.Decimal{}
+		placed = map[string]bool{}
+	)
+	for s, ps := range portfolio.Stocks {
+		shares := ps.Shares()
+		if !shares.IsPositive() {
+			continue
+		}
+		p := UnitPrice(price, s, date)
+		if !p.IsPositive() {
+			continue
+		}
+		prices[s] = p
+		value := p.Mul(shares)
+		for key, w := range s.Allocation(d) {
+			amount, ok := amounts[key]
+			if !ok || current[key].IsZero() {
+				continue
+			}
+			placed[key] = true
+			trades[s] = trades[s].Add(amount.Mul(value.Mul(w)).Div(current[key]))
+		}
+	}
+	for key, amount := range amounts {
+		if !placed[key] && amount.IsPositive() {
+			rebalance.Unallocated = rebalance.Unallocated.Add(amount)
+		}
+	}
+
+	for s, amount := range trades {
+		var (
+			p      = prices[s]
+			held   = portfolio.Stocks[s].Shares()
+			shares = amount.Div(p)
+		)
+		if held.Equal(held.Truncate(0)) {
+			shares = shares.Truncate(0)
+		}
+		shares = decimal.Max(shares, held.Neg())
+
+		trade := RebalanceTrade{
+			Stock:  s,
+			Type:   Buy,
+			Shares: shares.Abs(),
+			Amount: shares.Abs().Mul(p),
+		}
+		if shares.IsNegative() {
+			trade.Type = Sell
+		}
+		if trade.Shares.IsZero() || trade.Amount.LessThan(options.MinTrade) {
+			continue
+		}
+		rebalance.Trades = append(rebalance.Trades, trade)
+	}
+	sort.Slice(rebalance.Trades, func(i, j int) bool {
+		a, b := rebalance.Trades[i], rebalance.Trades[j]
+		if a.Type != b.Type {
+			return a.Type == Sell
+		}
+		if !a.Amount.Equal(b.Amount) {
+			return a.Amount.GreaterThan(b.Amount)
+		}
+		return a.Stock.ID() < b.Stock.ID()
+	})
+
+	return rebalance
+}
@@ -0,0 +1,104 @@
+package cf
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestCalculateRebalance(t *testing.T) {
+	var (
+		equity = &Stock{ISIN: "IE0000000001", AssetClass: "equity"}
+		bond   = &Stock{ISIN: "IE0000000002", AssetClass: "bond"}
+	)
+	equity.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2020, 1, 1),
+			Amount: decimal.RequireFromString("-800"),
+			Shares: decimal.RequireFromString("-8"),
+			Stock:  equity,
+		},
+	}
+	bond.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2020, 1, 1),
+			Amount: decimal.RequireFromString("-200"),
+			Shares: decimal.RequireFromString("-4"),
+			Stock:  bond,
+		},
+	}
+
+	transactions, stats, err := CalculateStats([]*Stock{equity, bond}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	price := func(s *Stock, date time.Time) decimal.Decimal {
+		if s == equity {
+			return decimal.NewFromInt(100)
+		}
+		return decimal.NewFromInt(50)
+	}
+
+	var (
+		date      = Date(2020, 12, 31)
+		portfolio = PortfolioAt(transactions, stats, date)
+		targets   = map[string]decimal.Decimal{
+			"equity": decimal.RequireFromString("0.6"),
+			"bond":   decimal.RequireFromString("0.4"),
+		}
+	)
+
+	testCases := map[string]struct {
+		Options  RebalanceOptions
+		Expected []RebalanceTrade
+	}{
+		"rebalance": {
+			Options: RebalanceOptions{},
+			Expected: []RebalanceTrade{
+				{Stock: equity, Type: Sell, Shares: decimal.NewFromInt(2), Amount: decimal.NewFromInt(200)},
+				{Stock: bond, Type: Buy, Shares: decimal.NewFromInt(4), Amount: decimal.NewFromInt(200)},
+			},
+		},
+		"buy only": {
+			Options: RebalanceOptions{Cash: decimal.NewFromInt(500), BuyOnly: true},
+			Expected: []RebalanceTrade{
+				{Stock: bond, Type: Buy, Shares: decimal.NewFromInt(8), Amount: decimal.NewFromInt(400)},
+				{Stock: equity, Type: Buy, Shares: decimal.NewFromInt(1), Amount: decimal.NewFromInt(100)},
+			},
+		},
+		"minimum trade": {
+			Options: RebalanceOptions{Cash: decimal.NewFromInt(500), BuyOnly: true, MinTrade: decimal.NewFromInt(150)},
+			Expected: []RebalanceTrade{
+				{Stock: bond, Type: Buy, Shares: decimal.NewFromInt(8), Amount: decimal.NewFromInt(400)},
+			},
+		},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			rebalance := CalculateRebalance(price, portfolio, date, AssetClass, targets, testCase.Options)
+			if len(rebalance.Trades) != len(testCase.Expected) {
+				t.Fatalf("expected %d trades, got %d", len(testCase.Expected), len(rebalance.Trades))
+			}
+			for i, expected := range testCase.Expected {
+				trade := rebalance.Trades[i]
+				if trade.Stock != expected.Stock || trade.Type != expected.Type || !trade.Shares.Equal(expected.Shares) || !trade.Amount.Equal(expected.Amount) {
+					t.Fatalf("expected trade %d to %s %s %s for %s, got %s %s %s for %s", i,
+						expected.Type, expected.Shares, expected.Stock.ISIN, expected.Amount,
+						trade.Type, trade.Shares, trade.Stock.ISIN, trade.Amount)
+				}
+			}
+		})
+	}
+
+	rebalance := CalculateRebalance(price, portfolio, date, AssetClass, targets, RebalanceOptions{})
+	for _, target := range rebalance.Targets {
+		if target.Key == "equity" && math.Abs(target.Drift-0.2) > 1e-9 {
+			t.Fatalf("expected drift of 0.2, got %f", target.Drift)
+		}
+	}
+}
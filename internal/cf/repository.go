@@ -4,4 +4,6 @@ import "context"
 
 type Repository interface {
 	Stocks(ctx context.Context) ([]*Stock, error)
+	Depots(ctx context.Context) ([]*Depot, error)
+	Targets(ctx context.Context) (Targets, error)
 }
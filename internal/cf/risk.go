@@ -0,0 +1,121 @@
+package cf
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// tradingDays is the number of trading days per year used to annualize
+// daily figures.
+const tradingDays = 252
+
+// Risk describes the risk of a portfolio over a period. Volatility is the
+// annualized standard deviation of the daily returns. MaxDrawdown is the
+// largest loss from a peak to a following trough, as a non-positive return.
+// Sharpe and Sortino are the annualized Sharpe and Sortino ratios.
+type Risk struct {
+	Volatility  float64
+	MaxDrawdown float64
+	Peak        time.Time
+	Trough      time.Time
+	Sharpe      float64
+	Sortino     float64
+}
+
+// CalculateRisk calculates the risk of the portfolio between begin and end
+// from the returns of its value on each weekday. Cash flows are assumed to
+// happen at the end of the day, like in CalculateTWR. riskFreeRate is the
+// annual risk-free rate, like 0.01 for 1%.
+func CalculateRisk(ctx context.Context, price PriceFunc, transactions Transactions, stats map[*Transaction]Stats, begin, end time.Time, riskFreeRate float64) Risk {
+	risk := Risk{
+		Volatility: math.NaN(),
+		Sharpe:     math.NaN(),
+		Sortino:    math.NaN(),
+	}
+	if len(transactions) == 0 {
+		return risk
+	}
+	if begin.Before(transactions[0].Date) {
+		begin = transactions[0].Date
+	}
+
+	var (
+		returns  []float64
+		value    = decimal.Zero // value on the last sampled day
+		inflow   = decimal.Zero
+		outflow  = decimal.Zero
+		index    = 1.0 // cumulative return index
+		peak     = 1.0
+		peakDate = begin
+		i        = 0
+	)
+	for date := begin; !date.After(end); date = date.AddDate(0, 0, 1) {
+		for ; i < len(transactions) && !transactions[i].Date.After(date); i++ {
+			if t := transactions[i]; !t.Type.IsCash() {
+				if flow := t.CashFlow(); flow.IsNegative() {
+					inflow = inflow.Sub(flow)
+				} else {
+					outflow = outflow.Add(flow)
+				}
+			}
+		}
+		if date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+			continue
+		}
+		if i == 0 {
+			continue
+		}
+
+		v := portfolioValue(price, stats[transactions[i-1]].Portfolio, date)
+		if value.IsPositive() {
+			r := Float64(v.Add(outflow).Sub(inflow).Div(value)) - 1
+			returns = append(returns, r)
+
+			index *= 1 + r
+			if index > peak {
+				peak, peakDate = index, date
+			} else if drawdown := index/peak - 1; drawdown < risk.MaxDrawdown {
+				risk.MaxDrawdown = drawdown
+				risk.Peak, risk.Trough = peakDate, date
+			}
+		} else if len(returns) == 0 {
+			peakDate = date
+		}
+		value, inflow, outflow = v, decimal.Zero, decimal.Zero
+	}
+
+	n := float64(len(returns))
+	if n < 2 {
+		return risk
+	}
+
+	var (
+		dailyRiskFree = riskFreeRate / tradingDays
+		mean          float64
+		variance      float64
+		downside      float64
+	)
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= n
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+		if excess := r - dailyRiskFree; excess < 0 {
+			downside += excess * excess
+		}
+	}
+	var (
+		stddev    = math.Sqrt(variance / (n - 1))
+		deviation = math.Sqrt(downside / n)
+		annualize = math.Sqrt(tradingDays)
+	)
+
+	risk.Volatility = stddev * annualize
+	risk.Sharpe = (mean - dailyRiskFree) / stddev * annualize
+	risk.Sortino = (mean - dailyRiskFree) / deviation * annualize
+	return risk
+}
@@ -0,0 +1,75 @@
+package cf
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestCalculateRisk(t *testing.T) {
+	stock := &Stock{ISIN: "US88160R1014"}
+	stock.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2020, 1, 6),
+			Amount: decimal.RequireFromString("-1000"),
+			Shares: decimal.RequireFromString("-10"),
+			Stock:  stock,
+		},
+	}
+
+	transactions, stats, err := CalculateStats([]*Stock{stock}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prices := map[time.Time]string{
+		Date(2020, 1, 6):  "100",
+		Date(2020, 1, 7):  "110",
+		Date(2020, 1, 8):  "99",
+		Date(2020, 1, 9):  "99",
+		Date(2020, 1, 10): "108.9",
+	}
+	price := func(stock *Stock, date time.Time) decimal.Decimal {
+		return decimal.RequireFromString(prices[date])
+	}
+
+	risk := CalculateRisk(context.Background(), price, transactions, stats, time.Time{}, Date(2020, 1, 10), 0)
+
+	var (
+		returns  = []float64{0.1, -0.1, 0, 0.1}
+		mean     = 0.025
+		variance float64
+	)
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	var (
+		stddev   = math.Sqrt(variance / 3)
+		downside = math.Sqrt(0.01 / 4)
+	)
+
+	testCases := map[string]struct {
+		Actual   float64
+		Expected float64
+	}{
+		"volatility":   {risk.Volatility, stddev * math.Sqrt(252)},
+		"max drawdown": {risk.MaxDrawdown, -0.1},
+		"sharpe":       {risk.Sharpe, mean / stddev * math.Sqrt(252)},
+		"sortino":      {risk.Sortino, mean / downside * math.Sqrt(252)},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if math.Abs(testCase.Actual-testCase.Expected) > 1e-9 {
+				t.Fatalf("expected %f, got %f", testCase.Expected, testCase.Actual)
+			}
+		})
+	}
+
+	if !risk.Peak.Equal(Date(2020, 1, 7)) || !risk.Trough.Equal(Date(2020, 1, 8)) {
+		t.Fatalf("expected drawdown from 2020-01-07 to 2020-01-08, got %s to %s", risk.Peak, risk.Trough)
+	}
+}
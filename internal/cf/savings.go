@@ -0,0 +1,163 @@
+package cf
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// savingsPlanSharePrecision is the number of decimal places of the
+	// shares bought by a savings plan.
+	savingsPlanSharePrecision = 6
+
+	// cryptoUnitPrecision is the number of decimal places of the units of
+	// crypto currencies bought by a savings plan.
+	cryptoUnitPrecision = 18
+)
+
+// SavingsPlan is a recurring buy of a stock for Amount, including Fees, every
+// Interval, which is Monthly if empty, from Start until End, which is zero for
+// plans without an end.
+// Monthly and quarterly plans are executed on Day of the month, or on its last
+// day in shorter months, and on the day of Start if Day is zero. Amount and
+// Fees are in Currency, or in the currency of the stock if it is empty.
+type SavingsPlan struct {
+	Amount   decimal.Decimal
+	Fees     decimal.Decimal
+	Currency string
+	Interval Interval
+	Start    time.Time
+	End      time.Time
+	Day      int
+	Depot    string
+}
+
+// Executions returns the execution dates of the plan up to and including
+// until.
+func (p SavingsPlan) Executions(until time.Time) []time.Time {
+	end := until
+	if !p.End.IsZero() && p.End.Before(end) {
+		end = p.End
+	}
+
+	var dates []time.Time
+	switch p.interval() {
+	case Monthly, Quarterly:
+		months := 1
+		if p.interval() == Quarterly {
+			months = 3
+		}
+		day := p.Day
+		if day == 0 {
+			day = p.Start.Day()
+		}
+		for i := 0; ; i++ {
+			first := time.Date(p.Start.Year(), p.Start.Month()+time.Month(i*months), 1, 0, 0, 0, 0, time.UTC)
+			last := first.AddDate(0, 1, -1).Day()
+			if day < last {
+				last = day
+			}
+			date := first.AddDate(0, 0, last-1)
+			if date.After(end) {
+				break
+			}
+			if !date.Before(p.Start) {
+				dates = append(dates, date)
+			}
+		}
+	default:
+		for date := p.Start; !date.After(end); date = p.interval().next(date) {
+			dates = append(dates, date)
+		}
+	}
+	return dates
+}
+
+// interval returns the Interval of the plan, which is Monthly if empty.
+func (p SavingsPlan) interval() Interval {
+	if p.Interval == "" {
+		return Monthly
+	}
+	return p.Interval
+}
+
+// ExpandSavingsPlans adds a buy for each execution of the savings plans of the
+// stock up to and including until, deriving the shares from the price on the
+// execution date. A buy marked as savings plan fill in the depot of a plan on
+// or after an execution date and before the next execution overrides the
+// execution with the real fill. Plans in another currency than the stock are
+// priced using conv. Executions without a price or exchange rate are skipped
+// until there is one, and their dates are returned.
+func (s *Stock) ExpandSavingsPlans(price PriceFunc, conv *Converter, until time.Time) []time.Time {
+	var (
+		explicit = s.Transactions
+		unpriced []time.Time
+	)
+	for _, plan := range s.SavingsPlans {
+		dates := plan.Executions(until)
+		for i, date := range dates {
+			next := plan.interval().next(date)
+			if i+1 < len(dates) {
+				next = dates[i+1]
+			}
+			if explicit.filled(plan.Depot, date, next) {
+				continue
+			}
+
+			shares, ok := plan.shares(s, UnitPrice(price, s, date), conv, date)
+			if !ok {
+				unpriced = append(unpriced, date)
+				continue
+			}
+			s.Transactions = append(s.Transactions, &Transaction{
+				Type:        Buy,
+				Date:        date,
+				Amount:      plan.Amount.Neg(),
+				Shares:      shares.Neg(),
+				Fees:        plan.Fees,
+				Currency:    plan.Currency,
+				Depot:       plan.Depot,
+				SavingsPlan: true,
+				Stock:       s,
+			})
+		}
+	}
+	s.Transactions.Sort()
+	return unpriced
+}
+
+// shares returns the shares of stock s bought by an execution of the plan on
+// date at price p, which is in the currency of the stock. It returns false if
+// there is no price or no exchange rate for the currency of the plan.
+func (p SavingsPlan) shares(s *Stock, price decimal.Decimal, conv *Converter, date time.Time) (decimal.Decimal, bool) {
+	amount := p.Amount.Sub(p.Fees)
+	if p.Currency != "" && p.Currency != s.Currency {
+		var err error
+		if amount, err = conv.Convert(amount, p.Currency, date); err != nil {
+			return decimal.Zero, false
+		}
+		if price, err = conv.Convert(price, s.Currency, date); err != nil {
+			return decimal.Zero, false
+		}
+	}
+	if !price.IsPositive() {
+		return decimal.Zero, false
+	}
+	precision := int32(savingsPlanSharePrecision)
+	if s.Kind == CryptoKind {
+		precision = cryptoUnitPrecision
+	}
+	return amount.DivRound(price, precision), true
+}
+
+// filled returns whether there is a buy marked as savings plan fill in depot on
+// or after begin and before end.
+func (ts Transactions) filled(depot string, begin, end time.Time) bool {
+	for _, t := range ts {
+		if t.Type == Buy && t.SavingsPlan && t.Depot == depot && !t.Date.Before(begin) && t.Date.Before(end) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+package cf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestSavingsPlanExecutions(t *testing.T) {
+	testCases := map[string]struct {
+		Plan     SavingsPlan
+		Until    time.Time
+		Expected []time.Time
+	}{
+		"monthly at the end of the month": {
+			Plan:     SavingsPlan{Interval: Monthly, Start: Date(2020, 1, 1), Day: 31},
+			Until:    Date(2020, 4, 15),
+			Expected: []time.Time{Date(2020, 1, 31), Date(2020, 2, 29), Date(2020, 3, 31)},
+		},
+		"monthly from the day of start": {
+			Plan:     SavingsPlan{Interval: Monthly, Start: Date(2020, 1, 15), End: Date(2020, 3, 1)},
+			Until:    Date(2020, 12, 31),
+			Expected: []time.Time{Date(2020, 1, 15), Date(2020, 2, 15)},
+		},
+		"quarterly after start": {
+			Plan:     SavingsPlan{Interval: Quarterly, Start: Date(2020, 1, 15), Day: 1},
+			Until:    Date(2020, 10, 1),
+			Expected: []time.Time{Date(2020, 4, 1), Date(2020, 7, 1), Date(2020, 10, 1)},
+		},
+		"monthly without interval": {
+			Plan:     SavingsPlan{Start: Date(2020, 1, 15)},
+			Until:    Date(2020, 3, 1),
+			Expected: []time.Time{Date(2020, 1, 15), Date(2020, 2, 15)},
+		},
+		"weekly": {
+			Plan:     SavingsPlan{Interval: Weekly, Start: Date(2020, 1, 6)},
+			Until:    Date(2020, 1, 20),
+			Expected: []time.Time{Date(2020, 1, 6), Date(2020, 1, 13), Date(2020, 1, 20)},
+		},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			dates := testCase.Plan.Executions(testCase.Until)
+			if len(dates) != len(testCase.Expected) {
+				t.Fatalf("expected %d executions, got %d", len(testCase.Expected), len(dates))
+			}
+			for i, expected := range testCase.Expected {
+				if !dates[i].Equal(expected) {
+					t.Fatalf("expected execution %d on %s, got %s", i, expected, dates[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExpandSavingsPlans(t *testing.T) {
+	stock := &Stock{
+		ISIN: "IE00B4L5Y983",
+		SavingsPlans: []SavingsPlan{
+			{
+				Amount:   decimal.RequireFromString("100"),
+				Fees:     decimal.RequireFromString("1"),
+				Interval: Monthly,
+				Start:    Date(2020, 1, 15),
+				Depot:    "comdirect",
+			},
+		},
+	}
+	fill := &Transaction{
+		Type:        Buy,
+		Date:        Date(2020, 2, 17),
+		Amount:      decimal.RequireFromString("-100"),
+		Shares:      decimal.RequireFromString("-2"),
+		Fees:        decimal.RequireFromString("1"),
+		Depot:       "comdirect",
+		SavingsPlan: true,
+		Stock:       stock,
+	}
+	manual := &Transaction{
+		Type:   Buy,
+		Date:   Date(2020, 3, 2),
+		Amount: decimal.RequireFromString("-500"),
+		Shares: decimal.RequireFromString("-10"),
+		Depot:  "comdirect",
+		Stock:  stock,
+	}
+	stock.Transactions = Transactions{fill, manual}
+
+	price := func(s *Stock, date time.Time) decimal.Decimal {
+		return decimal.NewFromInt(33)
+	}
+
+	if unpriced := stock.ExpandSavingsPlans(price, nil, Date(2020, 3, 31)); len(unpriced) != 0 {
+		t.Fatalf("expected all executions to be priced, got %d without price", len(unpriced))
+	}
+
+	if n := len(stock.Transactions); n != 4 {
+		t.Fatalf("expected 4 transactions, got %d", n)
+	}
+	if stock.Transactions[1] != fill {
+		t.Fatal("expected the February execution to be overridden by the fill")
+	}
+	if stock.Transactions[2] != manual {
+		t.Fatal("expected the manual buy not to override the March execution")
+	}
+
+	testCases := map[string]struct {
+		Actual   decimal.Decimal
+		Expected string
+	}{
+		"january amount": {stock.Transactions[0].Amount, "-100"},
+		"january shares": {stock.Transactions[0].Shares, "-3"},
+		"march shares":   {stock.Transactions[3].Shares, "-3"},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if expected := decimal.RequireFromString(testCase.Expected); !testCase.Actual.Equal(expected) {
+				t.Fatalf("expected %s, got %s", expected, testCase.Actual)
+			}
+		})
+	}
+
+	if date := stock.Transactions[0].Date; !date.Equal(Date(2020, 1, 15)) {
+		t.Fatalf("expected first execution on 2020-01-15, got %s", date)
+	}
+}
+
+func TestExpandSavingsPlansMissingPrice(t *testing.T) {
+	stock := &Stock{
+		ISIN: "IE00B4L5Y983",
+		SavingsPlans: []SavingsPlan{
+			{
+				Amount:   decimal.RequireFromString("100"),
+				Interval: Monthly,
+				Start:    Date(2020, 1, 15),
+			},
+		},
+	}
+	price := func(s *Stock, date time.Time) decimal.Decimal {
+		if date.Before(Date(2020, 3, 1)) {
+			return decimal.Zero
+		}
+		return decimal.NewFromInt(33)
+	}
+	unpriced := stock.ExpandSavingsPlans(price, nil, Date(2020, 3, 31))
+	if len(unpriced) != 2 || !unpriced[0].Equal(Date(2020, 1, 15)) || !unpriced[1].Equal(Date(2020, 2, 15)) {
+		t.Fatalf("expected the January and February executions without price, got %v", unpriced)
+	}
+	if n := len(stock.Transactions); n != 1 || !stock.Transactions[0].Date.Equal(Date(2020, 3, 15)) {
+		t.Fatalf("expected only the March execution, got %d transactions", n)
+	}
+}
+
+func TestExpandSavingsPlansCurrency(t *testing.T) {
+	stock := &Stock{
+		ISIN:     "IE00B4L5Y983",
+		Currency: "USD",
+		SavingsPlans: []SavingsPlan{
+			{
+				Amount:   decimal.RequireFromString("90"),
+				Currency: "EUR",
+				Interval: Monthly,
+				Start:    Date(2020, 1, 15),
+			},
+		},
+	}
+	price := func(s *Stock, date time.Time) decimal.Decimal {
+		return decimal.NewFromInt(20)
+	}
+	conv := NewConverter("EUR", func(from, to string, date time.Time) decimal.Decimal {
+		if date.Before(Date(2020, 2, 1)) {
+			return decimal.Zero
+		}
+		return decimal.RequireFromString("0.9")
+	})
+
+	if unpriced := stock.ExpandSavingsPlans(price, conv, Date(2020, 2, 29)); len(unpriced) != 1 {
+		t.Fatalf("expected the execution without exchange rate to be skipped, got %d", len(unpriced))
+	}
+	if n := len(stock.Transactions); n != 1 {
+		t.Fatalf("expected 1 transaction, got %d", n)
+	}
+	if buy := stock.Transactions[0]; !buy.Shares.Equal(decimal.NewFromInt(-5)) || buy.Currency != "EUR" {
+		t.Fatalf("expected 5 shares bought in EUR, got %s in %q", buy.Shares.Neg(), buy.Currency)
+	}
+}
@@ -0,0 +1,178 @@
+package cf
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// OversellError is the error of a sell of more shares than held or a cover
+// of more shares than sold short. Shortfall is the number of missing shares.
+type OversellError struct {
+	Stock     *Stock
+	Type      TransactionType
+	Depot     string
+	Date      time.Time
+	Shortfall decimal.Decimal
+}
+
+func newOversellError(t *Transaction, shortfall decimal.Decimal) *OversellError {
+	return &OversellError{
+		Stock:     t.Stock,
+		Type:      t.Type,
+		Depot:     t.Depot,
+		Date:      t.Date,
+		Shortfall: shortfall,
+	}
+}
+
+func (e *OversellError) Error() string {
+	held := "held"
+	if e.Type == Cover || e.Type == Assignment {
+		held = "sold short"
+	}
+	stock := "unknown stock"
+	if e.Stock != nil {
+		stock = e.Stock.ID()
+		if e.Stock.Name != "" {
+			stock = fmt.Sprintf("%s (%s)", e.Stock.Name, e.Stock.ID())
+		}
+	}
+	return fmt.Sprintf("cf: %s of %s shares more than %s of %s in depot %q on %s",
+		e.Type, e.Shortfall, held, stock, e.Depot, e.Date.Format("2006-01-02"))
+}
+
+// Short opens a short batch of stock s for the shares sold short by t.
+func (p Portfolio) Short(s *Stock, t *Transaction) {
+	ps, ok := p.Stocks[s]
+	if !ok {
+		ps = &PortfolioStock{}
+		p.Stocks[s] = ps
+	}
+	ps.Short(t)
+}
+
+// Cover closes the short batches of stock s bought back by t.
+func (p Portfolio) Cover(s *Stock, t *Transaction) (SellStats, error) {
+	if p.Stocks[s] == nil {
+		return SellStats{}, newOversellError(t, t.Shares.Neg())
+	}
+	return p.Stocks[s].Cover(t, p.LotMethod(s, t.Depot))
+}
+
+// Short adds a batch with the negative number of shares sold short by t. Its
+// price per share is the net proceeds per share.
+func (ps *PortfolioStock) Short(t *Transaction) {
+	ps.addBatch(t, t.Shares.Neg())
+}
+
+// Cover closes the short batches bought back by t in the order given by
+// method. The profit is the proceeds of the short sales minus the cost of the
+// cover, and the return is relative to the proceeds.
+func (ps *PortfolioStock) Cover(t *Transaction, method LotMethod) (SellStats, error) {
+	if err := checkLot(t, method); err != nil {
+		return SellStats{}, err
+	}
+	var (
+		shares    = t.Shares.Neg()
+		lots      = ps.shortLots(t, method)
+		available = decimal.Zero
+	)
+	for _, i := range lots {
+		available = available.Sub(ps.Batches[i].Shares)
+	}
+	if available.LessThan(shares) {
+		return SellStats{}, newOversellError(t, shares.Sub(available))
+	}
+
+	var (
+		toCover       = shares
+		proceeds      = decimal.Zero
+		grossProceeds = decimal.Zero
+		removed       = map[int]bool{}
+		covered       []SoldLot
+	)
+
+	for _, i := range lots {
+		if !toCover.IsPositive() {
+			break
+		}
+
+		b := ps.Batches[i]
+		part := b
+		if toCover.GreaterThanOrEqual(b.Shares.Neg()) {
+			removed[i] = true
+		} else {
+			part = b.part(toCover.Neg())
+			ps.Batches[i].Shares = b.Shares.Sub(part.Shares)
+			ps.Batches[i].Fees = b.Fees.Sub(part.Fees)
+			ps.Batches[i].Taxes = b.Taxes.Sub(part.Taxes)
+			ps.Batches[i].Transactions = append(b.Transactions, &Transaction{
+				Type:   t.Type,
+				Date:   t.Date,
+				Amount: t.Amount.Mul(part.Shares).Div(t.Shares),
+				Shares: part.Shares,
+				Fees:   t.Fees.Mul(part.Shares).Div(t.Shares),
+				Taxes:  t.Taxes.Mul(part.Shares).Div(t.Shares),
+				Depot:  t.Depot,
+				Stock:  t.Stock,
+			})
+		}
+		toCover = toCover.Add(part.Shares)
+		proceeds = proceeds.Sub(part.Invested())
+		grossProceeds = grossProceeds.Sub(part.GrossInvested())
+		covered = append(covered, part.covered(t))
+	}
+
+	if len(removed) > 0 {
+		batches := ps.Batches[:0:0]
+		for i, b := range ps.Batches {
+			if !removed[i] {
+				batches = append(batches, b)
+			}
+		}
+		ps.Batches = batches
+	}
+
+	var (
+		profit      = proceeds.Add(t.Amount)
+		grossProfit = grossProceeds.Add(t.GrossAmount())
+	)
+	ps.RealizedProfit = ps.RealizedProfit.Add(profit)
+	ps.GrossRealizedProfit = ps.GrossRealizedProfit.Add(grossProfit)
+	ps.Fees = ps.Fees.Add(t.Fees)
+	ps.Taxes = ps.Taxes.Add(t.Taxes)
+
+	return SellStats{
+		Return:        Return(proceeds, proceeds.Add(profit)),
+		Profit:        profit,
+		GrossReturn:   Return(grossProceeds, grossProceeds.Add(grossProfit)),
+		GrossProfit:   grossProfit,
+		PricePerShare: t.Amount.Div(t.Shares),
+		Lots:          covered,
+	}, nil
+}
+
+// covered returns the short batch as a lot covered by t, which may cover more
+// shares than the batch has.
+func (b PortfolioStockBatch) covered(t *Transaction) SoldLot {
+	var (
+		share    = func(d decimal.Decimal) decimal.Decimal { return d.Mul(b.Shares).Div(t.Shares) }
+		proceeds = b.Invested().Neg()
+		cost     = share(t.Amount).Neg()
+	)
+	return SoldLot{
+		Lot:            b.Lot,
+		Acquired:       b.Date,
+		Sold:           t.Date,
+		Shares:         b.Shares.Neg(),
+		Proceeds:       proceeds,
+		GrossProceeds:  b.GrossInvested().Neg(),
+		CostBasis:      cost,
+		GrossCostBasis: share(t.GrossAmount()).Neg(),
+		Fees:           b.Fees.Add(share(t.Fees)),
+		Taxes:          b.Taxes.Add(share(t.Taxes)),
+		Profit:         proceeds.Sub(cost),
+	}
+}
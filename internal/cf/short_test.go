@@ -0,0 +1,177 @@
+package cf
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestShort(t *testing.T) {
+	stock := &Stock{ISIN: "US88160R1014"}
+	stock.Transactions = Transactions{
+		{
+			Type:   Short,
+			Date:   Date(2020, 1, 1),
+			Amount: decimal.RequireFromString("990"),
+			Shares: decimal.RequireFromString("10"),
+			Fees:   decimal.RequireFromString("10"),
+			Depot:  "margin",
+			Stock:  stock,
+		},
+		{
+			Type:   Cover,
+			Date:   Date(2020, 3, 1),
+			Amount: decimal.RequireFromString("-204"),
+			Shares: decimal.RequireFromString("-4"),
+			Fees:   decimal.RequireFromString("4"),
+			Depot:  "margin",
+			Stock:  stock,
+		},
+		{
+			Type:   Cover,
+			Date:   Date(2020, 6, 1),
+			Amount: decimal.RequireFromString("-724"),
+			Shares: decimal.RequireFromString("-6"),
+			Fees:   decimal.RequireFromString("4"),
+			Depot:  "margin",
+			Stock:  stock,
+		},
+	}
+
+	transactions, stats, err := CalculateStats([]*Stock{stock}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		short  = stats[stock.Transactions[0]]
+		cover  = stats[stock.Transactions[1]]
+		closed = stats[stock.Transactions[2]]
+		gains  = CalculateRealizedGains(transactions, stats, Date(2020, 1, 1), Date(2020, 12, 31))
+		price  = func(stock *Stock, date time.Time) decimal.Decimal {
+			return decimal.RequireFromString("100")
+		}
+	)
+
+	testCases := map[string]struct {
+		Actual   decimal.Decimal
+		Expected string
+	}{
+		"short shares":         {short.Portfolio.Stocks[stock].Shares(), "-10"},
+		"short invested":       {short.Portfolio.Stocks[stock].Invested(), "-990"},
+		"short value":          {short.Portfolio.Value(price, Date(2020, 1, 1)), "-1000"},
+		"cover shares":         {cover.Portfolio.Stocks[stock].Shares(), "-6"},
+		"cover profit":         {cover.Sell.Profit, "192"},
+		"cover gross profit":   {cover.Sell.GrossProfit, "200"},
+		"cover lot proceeds":   {cover.Sell.Lots[0].Proceeds, "396"},
+		"cover lot cost":       {cover.Sell.Lots[0].CostBasis, "204"},
+		"closed shares":        {closed.Portfolio.Stocks[stock].Shares(), "0"},
+		"closed profit":        {closed.Sell.Profit, "-130"},
+		"realized profit":      {closed.Portfolio.RealizedProfit(), "62"},
+		"realized gain shares": {gains[0].Shares.Add(gains[1].Shares), "10"},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if expected := decimal.RequireFromString(testCase.Expected); !testCase.Actual.Equal(expected) {
+				t.Fatalf("expected %s, got %s", expected, testCase.Actual)
+			}
+		})
+	}
+
+	if r := cover.Sell.Return; r < 0.4848 || r > 0.4849 {
+		t.Errorf("unexpected cover return: %f", r)
+	}
+	for _, b := range cover.Portfolio.Stocks[stock].Batches {
+		if _, err := b.Transactions.Stats(); err != nil {
+			t.Errorf("replaying batch %s: %s", b.Lot, err)
+		}
+	}
+}
+
+func TestCoverTax(t *testing.T) {
+	var (
+		stock = &Stock{ISIN: "US88160R1014"}
+		depot = &Depot{Name: "margin", Tax: &GermanTax{}}
+	)
+	stock.Transactions = Transactions{
+		{
+			Type:   Short,
+			Date:   Date(2020, 1, 1),
+			Amount: decimal.RequireFromString("1000"),
+			Shares: decimal.RequireFromString("10"),
+			Depot:  "margin",
+			Stock:  stock,
+		},
+		{
+			Type:   Cover,
+			Date:   Date(2020, 3, 1),
+			Amount: decimal.RequireFromString("-790"),
+			Shares: decimal.RequireFromString("-10"),
+			Taxes:  decimal.RequireFromString("10"),
+			Depot:  "margin",
+			Stock:  stock,
+		},
+	}
+
+	_, stats, err := CalculateStats([]*Stock{stock}, []*Depot{depot})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The taxes withheld are part of the taxable income of 220.
+	cover := stats[stock.Transactions[1]]
+	testCases := map[string]struct {
+		Actual   decimal.Decimal
+		Expected string
+	}{
+		"profit":           {cover.Sell.Profit, "210"},
+		"tax":              {cover.Sell.Tax, "58.03"},
+		"after tax profit": {cover.Sell.AfterTaxProfit, "161.97"},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if expected := decimal.RequireFromString(testCase.Expected); !testCase.Actual.Equal(expected) {
+				t.Fatalf("expected %s, got %s", expected, testCase.Actual)
+			}
+		})
+	}
+}
+
+func TestOversell(t *testing.T) {
+	stock := &Stock{Name: "Tesla", ISIN: "US88160R1014"}
+	stock.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2020, 1, 1),
+			Amount: decimal.RequireFromString("-300"),
+			Shares: decimal.RequireFromString("-3"),
+			Depot:  "comdirect",
+			Stock:  stock,
+		},
+		{
+			Type:   Sell,
+			Date:   Date(2020, 2, 1),
+			Amount: decimal.RequireFromString("500"),
+			Shares: decimal.RequireFromString("5"),
+			Depot:  "comdirect",
+			Stock:  stock,
+		},
+	}
+
+	_, _, err := CalculateStats([]*Stock{stock}, nil)
+	var oversell *OversellError
+	if !errors.As(err, &oversell) {
+		t.Fatalf("expected oversell error, got %v", err)
+	}
+	if oversell.Stock != stock || oversell.Depot != "comdirect" || !oversell.Date.Equal(Date(2020, 2, 1)) {
+		t.Errorf("unexpected error: %+v", oversell)
+	}
+	if expected := decimal.NewFromInt(2); !oversell.Shortfall.Equal(expected) {
+		t.Errorf("expected shortfall %s, got %s", expected, oversell.Shortfall)
+	}
+	if expected := `cf: sell of 2 shares more than held of Tesla (US88160R1014) in depot "comdirect" on 2020-02-01`; err.Error() != expected {
+		t.Errorf("unexpected message: %s", err)
+	}
+}
@@ -0,0 +1,69 @@
+package cf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Ratio is the ratio of new to old shares of a split, written as "new:old".
+// A 5:1 split turns one share into five, a 1:10 reverse split turns ten
+// shares into one.
+type Ratio struct {
+	New decimal.Decimal
+	Old decimal.Decimal
+}
+
+func ParseRatio(s string) (Ratio, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return Ratio{}, fmt.Errorf("cf: invalid ratio %q", s)
+	}
+	n, err := decimal.NewFromString(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return Ratio{}, fmt.Errorf("cf: invalid ratio %q: %w", s, err)
+	}
+	o, err := decimal.NewFromString(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return Ratio{}, fmt.Errorf("cf: invalid ratio %q: %w", s, err)
+	}
+	if !n.IsPositive() || !o.IsPositive() {
+		return Ratio{}, fmt.Errorf("cf: invalid ratio %q", s)
+	}
+	return Ratio{New: n, Old: o}, nil
+}
+
+func (r Ratio) String() string {
+	return r.New.String() + ":" + r.Old.String()
+}
+
+// Shares returns the number of shares after the split.
+func (r Ratio) Shares(shares decimal.Decimal) decimal.Decimal {
+	return shares.Mul(r.New).Div(r.Old)
+}
+
+// Price returns the price per share after the split.
+func (r Ratio) Price(price decimal.Decimal) decimal.Decimal {
+	return price.Mul(r.Old).Div(r.New)
+}
+
+// adjustForSplits sets the split-adjusted prices of all buys, taking into
+// account all splits after the buy.
+func adjustForSplits(transactions Transactions, stats map[*Transaction]Stats) {
+	ratios := map[*Stock][]Ratio{}
+	for i := len(transactions) - 1; i >= 0; i-- {
+		t := transactions[i]
+		switch t.Type {
+		case Split:
+			ratios[t.Stock] = append(ratios[t.Stock], t.Ratio)
+		case Buy:
+			s := stats[t]
+			s.Buy.AdjustedPricePerShare = s.Buy.PricePerShare
+			for _, r := range ratios[t.Stock] {
+				s.Buy.AdjustedPricePerShare = r.Price(s.Buy.AdjustedPricePerShare)
+			}
+			stats[t] = s
+		}
+	}
+}
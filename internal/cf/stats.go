@@ -1,7 +1,9 @@
 package cf
 
 import (
+	"fmt"
 	"sort"
+	"time"
 
 	"github.com/shopspring/decimal"
 )
@@ -12,23 +14,85 @@ type Stats struct {
 	Dividend    DividendStats
 	Buy         BuyStats
 	Sell        SellStats
+	Transfer    TransferStats
 }
 
+// DividendStats are the stats of a dividend. Tax is the tax on the dividend,
+// estimated by the depot's tax engine if it has one.
 type DividendStats struct {
-	Return float64
+	Return         float64
+	GrossReturn    float64
+	Tax            decimal.Decimal
+	AfterTaxAmount decimal.Decimal
 }
 
 type BuyStats struct {
-	PricePerShare decimal.Decimal
+	PricePerShare      decimal.Decimal
+	GrossPricePerShare decimal.Decimal
+
+	// AdjustedPricePerShare is PricePerShare adjusted for all later splits.
+	AdjustedPricePerShare decimal.Decimal
 }
 
 type SellStats struct {
 	Return        float64
 	Profit        decimal.Decimal
+	GrossReturn   float64
+	GrossProfit   decimal.Decimal
 	PricePerShare decimal.Decimal
+
+	// Tax is the tax on the profit, estimated by the depot's tax engine if
+	// it has one.
+	Tax            decimal.Decimal
+	AfterTaxProfit decimal.Decimal
+
+	// Lots are the buy lots matched by the sell or the short lots matched
+	// by the cover.
+	Lots []SoldLot
+}
+
+// SoldLot is the part of a buy lot matched by a sell. Proceeds and Profit are
+// net of the fees and taxes of both the buy and the sell, which are included
+// in Fees and Taxes. For a short lot matched by a cover, Acquired is the date
+// of the short sale and Proceeds are those of the short sale.
+type SoldLot struct {
+	Lot            string
+	Acquired       time.Time
+	Sold           time.Time
+	Shares         decimal.Decimal
+	Proceeds       decimal.Decimal
+	GrossProceeds  decimal.Decimal
+	CostBasis      decimal.Decimal
+	GrossCostBasis decimal.Decimal
+	Fees           decimal.Decimal
+	Taxes          decimal.Decimal
+	Profit         decimal.Decimal
+}
+
+// HoldingDays returns the number of days the lot was held.
+func (l SoldLot) HoldingDays() int {
+	return days(l.Acquired, l.Sold)
+}
+
+// LongTerm returns whether the lot was held for more than one year.
+func (l SoldLot) LongTerm() bool {
+	return l.Sold.After(l.Acquired.AddDate(1, 0, 0))
 }
 
-func CalculateStats(stocks []*Stock) (Transactions, map[*Transaction]Stats, error) {
+// CalculateStats calculates the stats of all transactions of stocks and the
+// cash transactions of depots in chronological order. depots may be nil.
+func CalculateStats(stocks []*Stock, depots []*Depot) (Transactions, map[*Transaction]Stats, error) {
+	return calculateStats(stocks, depots, true)
+}
+
+// CalculateStockStats calculates the stats of the transactions of stocks like
+// CalculateStats, using the lot methods and tax engines of depots but neither
+// their cash transactions nor cash balances.
+func CalculateStockStats(stocks []*Stock, depots []*Depot) (Transactions, map[*Transaction]Stats, error) {
+	return calculateStats(stocks, depots, false)
+}
+
+func calculateStats(stocks []*Stock, depots []*Depot, cash bool) (Transactions, map[*Transaction]Stats, error) {
 	type stockTransaction struct {
 		stock *Stock
 		tx    *Transaction
@@ -42,6 +106,16 @@ func CalculateStats(stocks []*Stock) (Transactions, map[*Transaction]Stats, erro
 			})
 		}
 	}
+	for _, depot := range depots {
+		if !cash {
+			continue
+		}
+		for _, tx := range depot.Transactions {
+			sts = append(sts, stockTransaction{
+				tx: tx,
+			})
+		}
+	}
 	sort.SliceStable(sts, func(i, j int) bool {
 		return sts[i].tx.Date.Before(sts[j].tx.Date)
 	})
@@ -52,39 +126,99 @@ func CalculateStats(stocks []*Stock) (Transactions, map[*Transaction]Stats, erro
 	for i, st := range sts {
 		transactions = append(transactions, st.tx)
 
-		s := Stats{
-			Transaction: st.tx,
-		}
-
+		var portfolio Portfolio
 		if i == 0 {
-			s.Portfolio = Portfolio{}
-		} else {
-			s.Portfolio = stats[sts[i-1].tx].Portfolio.Clone()
-		}
-
-		if st.tx.Shares.IsPositive() {
-			// Sell
-			ret, profit, err := s.Portfolio.RemoveShares(st.stock, st.tx)
-			if err != nil {
-				return nil, nil, err
+			portfolio = NewPortfolio(depots)
+			if !cash {
+				portfolio.Cash = map[string]decimal.Decimal{}
 			}
-			s.Sell.Return, s.Sell.Profit = ret, profit
-			s.Sell.PricePerShare = st.tx.Amount.Div(st.tx.Shares)
-		} else if st.tx.Shares.IsNegative() {
-			// Buy
-			s.Portfolio.AddShares(st.stock, st.tx)
-			s.Buy.PricePerShare = st.tx.Amount.Div(st.tx.Shares)
 		} else {
-			// Dividend
-			ret, err := s.Portfolio.AddDividend(st.stock, st.tx)
-			if err != nil {
-				return nil, nil, err
-			}
-			s.Dividend.Return = ret
+			portfolio = stats[sts[i-1].tx].Portfolio.Clone()
 		}
 
+		s, err := portfolio.apply(st.stock, st.tx)
+		if err != nil {
+			return nil, nil, err
+		}
 		stats[st.tx] = s
 	}
 
+	adjustForSplits(transactions, stats)
 	return transactions, stats, nil
 }
+
+// apply applies the transaction t of stock s to the portfolio and returns
+// the resulting stats.
+func (p Portfolio) apply(s *Stock, t *Transaction) (Stats, error) {
+	stats := Stats{
+		Transaction: t,
+		Portfolio:   p,
+	}
+
+	switch t.Type {
+	case Sell, Exercise:
+		sell, err := p.RemoveShares(s, t.principal())
+		if err != nil {
+			return Stats{}, err
+		}
+		income := sell.Profit.Add(t.Taxes).Add(t.AccruedInterest)
+		sell.Tax = p.tax(s, t, income)
+		sell.AfterTaxProfit = income.Sub(sell.Tax)
+		p.Stocks[s].addTax(sell.Tax, sell.AfterTaxProfit)
+		p.Stocks[s].addAccruedInterest(t)
+		stats.Sell = sell
+	case Short:
+		p.Short(s, t)
+	case Cover, Assignment:
+		cover, err := p.Cover(s, t)
+		if err != nil {
+			return Stats{}, err
+		}
+		income := cover.Profit.Add(t.Taxes)
+		cover.Tax = p.tax(s, t, income)
+		cover.AfterTaxProfit = income.Sub(cover.Tax)
+		p.Stocks[s].addTax(cover.Tax, cover.AfterTaxProfit)
+		stats.Sell = cover
+	case Buy:
+		p.AddShares(s, t.principal())
+		if !t.AccruedInterest.IsZero() {
+			tax := p.tax(s, t, t.AccruedInterest)
+			p.Stocks[s].addTax(tax, t.AccruedInterest.Sub(tax))
+			p.Stocks[s].addAccruedInterest(t)
+		}
+		stats.Buy.PricePerShare = t.principal().Amount.Div(t.Shares)
+		stats.Buy.GrossPricePerShare = t.principal().GrossAmount().Div(t.Shares)
+	case Dividend, Coupon:
+		dividend, err := p.AddDividend(s, t)
+		if err != nil {
+			return Stats{}, err
+		}
+		income := t.Amount.Add(t.Taxes)
+		dividend.Tax = p.tax(s, t, income)
+		dividend.AfterTaxAmount = income.Sub(dividend.Tax)
+		p.Stocks[s].addTax(dividend.Tax, dividend.AfterTaxAmount)
+		if t.Reinvested() {
+			p.Stocks[s].Reinvest(t)
+		}
+		stats.Dividend = dividend
+	case Split:
+		p.Split(s, t)
+	case Merger, SpinOff:
+		p.CorporateAction(s, t)
+	case Transfer:
+		transfer, err := p.Transfer(s, t)
+		if err != nil {
+			return Stats{}, err
+		}
+		stats.Transfer = transfer
+		// Transfers move shares, not cash.
+		return stats, nil
+	case Deposit, Withdrawal, Interest, Fee:
+		// Only changes the cash balance
+	default:
+		return Stats{}, fmt.Errorf("cf: invalid transaction type %q", t.Type)
+	}
+	p.credit(t.Depot, t.CashFlow())
+
+	return stats, nil
+}
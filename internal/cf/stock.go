@@ -1,15 +1,186 @@
 package cf
 
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Kind is the kind of instrument of a stock.
+type Kind string
+
+const (
+	// StockKind are shares and funds quoted per share.
+	StockKind Kind = ""
+	// BondKind are bonds quoted in percent of their nominal value.
+	BondKind Kind = "bond"
+	// CryptoKind are crypto currencies identified by their symbol, whose
+	// units have up to 18 decimals.
+	CryptoKind Kind = "crypto"
+	// OptionKind and WarrantKind are derivatives of an underlying stock,
+	// quoted per unit of the underlying.
+	OptionKind  Kind = "option"
+	WarrantKind Kind = "warrant"
+)
+
+func ParseKind(s string) (Kind, error) {
+	switch k := Kind(s); k {
+	case StockKind, BondKind, CryptoKind, OptionKind, WarrantKind:
+		return k, nil
+	default:
+		return "", fmt.Errorf("cf: invalid instrument kind %q", s)
+	}
+}
+
 type Stock struct {
-	Name         string
-	Symbol       string
-	ISIN         string
+	Name      string
+	Symbol    string
+	ISIN      string
+	Currency  string
+	LotMethod LotMethod
+	FundType  FundType
+	Kind      Kind
+
+	// Nominal is the nominal value of one unit of a bond, which pays the
+	// yearly Coupon rate on it Frequency times a year and is redeemed on
+	// Maturity.
+	Nominal   decimal.Decimal
+	Coupon    decimal.Decimal
+	Frequency int
+	Maturity  time.Time
+
+	// Underlying is the ID of the underlying stock of an option or
+	// warrant, which is the right to buy (call) or sell (put) ContractSize
+	// shares of the underlying per contract at Strike until Expiry.
+	Underlying   string
+	OptionType   OptionType
+	Strike       decimal.Decimal
+	Expiry       time.Time
+	ContractSize decimal.Decimal
+
+	AssetClass string
+	Sector     string
+	Country    string
+	Tags       []string
+
+	// Weights are the look-through weights of funds by dimension, like
+	// 0.6 for "US" and 0.4 for "DE" by Country.
+	Weights map[Dimension]map[string]decimal.Decimal
+
+	// SavingsPlans are expanded into transactions by ExpandSavingsPlans.
+	SavingsPlans []SavingsPlan
+
 	Transactions Transactions
 }
 
+// ID returns the ISIN of the stock or, for stocks without an ISIN like crypto
+// currencies and benchmarks given by symbol, its symbol.
+func (s *Stock) ID() string {
+	if s.ISIN != "" {
+		return s.ISIN
+	}
+	return s.Symbol
+}
+
+// Since returns the date of the first transaction or savings plan execution
+// of the stock, from which on its prices are needed, or the zero time if it
+// has neither.
+func (s *Stock) Since() time.Time {
+	var since time.Time
+	for _, t := range s.Transactions {
+		if since.IsZero() || t.Date.Before(since) {
+			since = t.Date
+		}
+	}
+	for _, plan := range s.SavingsPlans {
+		if since.IsZero() || plan.Start.Before(since) {
+			since = plan.Start
+		}
+	}
+	return since
+}
+
+// Expired returns whether the stock is a derivative after its Expiry or a
+// bond after its Maturity on date, which are no longer traded.
+func (s *Stock) Expired(date time.Time) bool {
+	switch {
+	case s.IsDerivative():
+		return !s.Expiry.IsZero() && date.After(s.Expiry)
+	case s.Kind == BondKind:
+		return !s.Maturity.IsZero() && date.After(s.Maturity)
+	}
+	return false
+}
+
+// Pair returns the symbol of the stock quoted in its currency, like BTC-EUR.
+func (s *Stock) Pair() string {
+	if s.Currency == "" {
+		return s.Symbol
+	}
+	return s.Symbol + "-" + s.Currency
+}
+
 func (s *Stock) Clone() *Stock {
 	cloned := &Stock{}
 	*cloned = *s
 	cloned.Transactions = s.Transactions.Clone()
+	for _, t := range cloned.Transactions {
+		t.Stock = cloned
+	}
+	return cloned
+}
+
+// ForDepot returns a copy of the stock with only the transactions in depot.
+// Splits, mergers and spin-offs apply to all depots and are always included.
+// Transfers out of the depot remove each lot moved at its cost basis, and
+// transfers into the depot add each lot moved with its original date and cost
+// basis. The lots moved are taken from stats, the stats of the stock
+// calculated with its related stocks and the depots, so that they follow the
+// lot methods of the depots.
+func (s *Stock) ForDepot(depot string, stats map[*Transaction]Stats) *Stock {
+	cloned := &Stock{}
+	*cloned = *s
+	cloned.Transactions = nil
+
+	for _, t := range s.Transactions {
+		var (
+			transferOut = t.Type == Transfer && t.Depot == depot && t.ToDepot != depot
+			transferIn  = t.Type == Transfer && t.ToDepot == depot && t.Depot != depot
+			moved       = stats[t].Transfer.Lots
+		)
+
+		switch {
+		case transferOut:
+			for _, b := range moved {
+				out := t.Clone()
+				out.ToDepot = ""
+				out.Amount = b.Invested()
+				out.Shares = b.Shares
+				out.Lot = b.Lot
+				out.Stock = cloned
+				cloned.Transactions = append(cloned.Transactions, out)
+			}
+		case transferIn:
+			for _, b := range moved {
+				cloned.Transactions = append(cloned.Transactions, &Transaction{
+					Type:     Transfer,
+					Date:     t.Date,
+					Amount:   b.Invested().Neg(),
+					Shares:   b.Shares.Neg(),
+					Fees:     b.Fees,
+					Taxes:    b.Taxes,
+					Lot:      b.Lot,
+					ToDepot:  depot,
+					Acquired: b.Date,
+					Stock:    cloned,
+				})
+			}
+		case t.Depot == depot || t.Type == Split || t.Type == Merger || t.Type == SpinOff:
+			t = t.Clone()
+			t.Stock = cloned
+			cloned.Transactions = append(cloned.Transactions, t)
+		}
+	}
 	return cloned
 }
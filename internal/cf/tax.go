@@ -0,0 +1,63 @@
+package cf
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// TaxEngine estimates the taxes on income realized in a depot. Depots
+// without a tax engine use the taxes recorded in the transactions.
+type TaxEngine interface {
+	// Tax returns the tax on income realized by t in stock s and the updated
+	// tax account of the depot. income is net of fees but before taxes and
+	// negative for losses.
+	Tax(account TaxAccount, s *Stock, t *Transaction, income decimal.Decimal) (decimal.Decimal, TaxAccount)
+}
+
+// TaxAccount is the tax state of a depot: the part of the tax-free allowance
+// used in Year and the losses carried forward.
+type TaxAccount struct {
+	Year          int
+	AllowanceUsed decimal.Decimal
+	Losses        decimal.Decimal
+}
+
+// tax returns the tax on income realized by t in stock s as estimated by the
+// tax engine of t's depot, or the taxes of t if the depot has none.
+func (p Portfolio) tax(s *Stock, t *Transaction, income decimal.Decimal) decimal.Decimal {
+	d := p.Depots[t.Depot]
+	if d == nil || d.Tax == nil {
+		return t.Taxes
+	}
+	tax, account := d.Tax.Tax(p.TaxAccounts[t.Depot], s, t, income)
+	p.TaxAccounts[t.Depot] = account
+	return tax
+}
+
+// FundType is the type of an investment fund, which determines the partial
+// exemption of its income from taxes.
+type FundType string
+
+const (
+	NoFund                FundType = ""
+	EquityFund            FundType = "equity"
+	MixedFund             FundType = "mixed"
+	RealEstateFund        FundType = "real_estate"
+	ForeignRealEstateFund FundType = "foreign_real_estate"
+	OtherFund             FundType = "other"
+)
+
+func ParseFundType(s string) (FundType, error) {
+	switch ft := FundType(s); ft {
+	case NoFund, EquityFund, MixedFund, RealEstateFund, ForeignRealEstateFund, OtherFund:
+		return ft, nil
+	default:
+		return "", fmt.Errorf("cf: invalid fund type %q", s)
+	}
+}
+
+// IsFund returns whether ft is an investment fund.
+func (ft FundType) IsFund() bool {
+	return ft != NoFund
+}
@@ -0,0 +1,199 @@
+package cf
+
+import (
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	germanTaxRate       = decimal.RequireFromString("0.25")
+	germanSoliRate      = decimal.RequireFromString("0.055")
+	germanBaseIncome    = decimal.RequireFromString("0.7")
+	germanTaxRateFactor = decimal.NewFromInt(4)
+)
+
+// GermanBaseRates are the base rates (Basiszins) for the Vorabpauschale by
+// year. Negative base rates are listed as zero.
+var GermanBaseRates = map[int]decimal.Decimal{
+	2018: decimal.RequireFromString("0.0087"),
+	2019: decimal.RequireFromString("0.0052"),
+	2020: decimal.RequireFromString("0.0007"),
+	2021: decimal.Zero,
+	2022: decimal.Zero,
+	2023: decimal.RequireFromString("0.0255"),
+	2024: decimal.RequireFromString("0.0229"),
+	2025: decimal.RequireFromString("0.0253"),
+}
+
+// GermanTax is the German flat tax on capital income (Abgeltungsteuer) of
+// 25% plus solidarity surcharge and optional church tax. Income is reduced
+// by the partial exemption of its fund type (Teilfreistellung), by losses
+// carried forward and by the yearly tax-free Allowance of the depot
+// (Freistellungsauftrag). Losses are carried forward without distinguishing
+// stock and other losses.
+type GermanTax struct {
+	Allowance     decimal.Decimal
+	ChurchTaxRate decimal.Decimal // like 0.08 or 0.09, zero without church tax
+}
+
+func (g *GermanTax) Tax(account TaxAccount, s *Stock, t *Transaction, income decimal.Decimal) (decimal.Decimal, TaxAccount) {
+	if year := t.Date.Year(); account.Year != year {
+		account.Year = year
+		account.AllowanceUsed = decimal.Zero
+	}
+
+	income = g.Taxable(s.FundType, income)
+	if income.IsNegative() {
+		account.Losses = account.Losses.Sub(income)
+		return decimal.Zero, account
+	}
+
+	offset := decimal.Min(income, account.Losses)
+	income = income.Sub(offset)
+	account.Losses = account.Losses.Sub(offset)
+
+	allowance := decimal.Min(income, decimal.Max(decimal.Zero, g.Allowance.Sub(account.AllowanceUsed)))
+	income = income.Sub(allowance)
+	account.AllowanceUsed = account.AllowanceUsed.Add(allowance)
+
+	return g.Rate(income), account
+}
+
+// Taxable returns the taxable part of income of a fund of type ft after the
+// partial exemption.
+func (g *GermanTax) Taxable(ft FundType, income decimal.Decimal) decimal.Decimal {
+	var exemption decimal.Decimal
+	switch ft {
+	case EquityFund:
+		exemption = decimal.RequireFromString("0.3")
+	case MixedFund:
+		exemption = decimal.RequireFromString("0.15")
+	case RealEstateFund:
+		exemption = decimal.RequireFromString("0.6")
+	case ForeignRealEstateFund:
+		exemption = decimal.RequireFromString("0.8")
+	default:
+		return income
+	}
+	return income.Sub(income.Mul(exemption))
+}
+
+// Rate returns the flat tax, solidarity surcharge and church tax on the
+// taxable income. Church tax is deductible, which lowers the flat tax to
+// income / (4 + church tax rate).
+func (g *GermanTax) Rate(income decimal.Decimal) decimal.Decimal {
+	if !income.IsPositive() {
+		return decimal.Zero
+	}
+	tax := income.Mul(germanTaxRate)
+	if g.ChurchTaxRate.IsPositive() {
+		tax = income.Div(germanTaxRateFactor.Add(g.ChurchTaxRate))
+	}
+	return tax.
+		Add(tax.Mul(germanSoliRate)).
+		Add(tax.Mul(g.ChurchTaxRate)).
+		Round(2)
+}
+
+// Vorabpauschale is the advance lump sum taxed yearly for the shares of a
+// fund held at the end of Year. Taxable is the amount after the partial
+// exemption. Tax is the tax at the full rate of the depot's German tax,
+// disregarding the allowance and losses carried forward.
+type Vorabpauschale struct {
+	Year    int
+	Stock   *Stock
+	Depot   string
+	Shares  decimal.Decimal
+	Amount  decimal.Decimal
+	Taxable decimal.Decimal
+	Tax     decimal.Decimal
+}
+
+// CalculateVorabpauschale calculates the Vorabpauschale of all funds for
+// year. It is the base income, 70% of the base rate applied to the price at
+// the beginning of the year, less the distributions of the year, and limited
+// to the increase in value plus distributions. Shares bought during the year
+// count for each month from the month of the buy. The Vorabpauschale is not
+// deducted from the gains of later sells.
+func CalculateVorabpauschale(price PriceFunc, transactions Transactions, stats map[*Transaction]Stats, year int) []Vorabpauschale {
+	baseRate, ok := GermanBaseRates[year]
+	if !ok || !baseRate.IsPositive() {
+		return nil
+	}
+
+	var (
+		begin     = time.Date(year-1, 12, 31, 0, 0, 0, 0, time.UTC)
+		end       = time.Date(year, 12, 31, 0, 0, 0, 0, time.UTC)
+		portfolio = PortfolioAt(transactions, stats, end)
+		results   []Vorabpauschale
+	)
+
+	for s, ps := range portfolio.Stocks {
+		if !s.FundType.IsFund() {
+			continue
+		}
+
+		var (
+			startPrice    = price(s, begin)
+			endPrice      = price(s, end)
+			distributions = decimal.Zero // per share
+		)
+		if !startPrice.IsPositive() {
+			continue
+		}
+		for _, t := range transactions {
+			if t.Stock != s || t.Type != Dividend || t.Date.Year() != year {
+				continue
+			}
+			if held := stats[t].Portfolio.Stocks[s]; held != nil && held.Shares().IsPositive() {
+				distributions = distributions.Add(t.GrossAmount().Div(held.Shares()))
+			}
+		}
+
+		var (
+			baseIncome = startPrice.Mul(baseRate).Mul(germanBaseIncome)
+			limit      = endPrice.Sub(startPrice).Add(distributions)
+			perShare   = decimal.Min(baseIncome, limit).Sub(distributions)
+		)
+		if !perShare.IsPositive() {
+			continue
+		}
+
+		byDepot := map[string]Vorabpauschale{}
+		for _, b := range ps.Batches {
+			shares := b.Shares
+			amount := perShare.Mul(shares)
+			if b.Date.Year() == year {
+				months := decimal.NewFromInt(int64(13 - b.Date.Month()))
+				amount = amount.Mul(months).Div(decimal.NewFromInt(12))
+			}
+			v := byDepot[b.Depot]
+			v.Shares = v.Shares.Add(shares)
+			v.Amount = v.Amount.Add(amount)
+			byDepot[b.Depot] = v
+		}
+
+		for depot, v := range byDepot {
+			v.Year, v.Stock, v.Depot = year, s, depot
+			v.Amount = v.Amount.Round(2)
+			v.Taxable = v.Amount
+			if d := portfolio.Depots[depot]; d != nil {
+				if g, ok := d.Tax.(*GermanTax); ok {
+					v.Taxable = g.Taxable(s.FundType, v.Amount)
+					v.Tax = g.Rate(v.Taxable)
+				}
+			}
+			results = append(results, v)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Stock.ISIN != results[j].Stock.ISIN {
+			return results[i].Stock.ISIN < results[j].Stock.ISIN
+		}
+		return results[i].Depot < results[j].Depot
+	})
+	return results
+}
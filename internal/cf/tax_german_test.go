@@ -0,0 +1,228 @@
+package cf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestGermanTaxRate(t *testing.T) {
+	testCases := map[string]struct {
+		ChurchTaxRate string
+		Income        string
+		Expected      string
+	}{
+		"without church tax": {"0", "1000", "263.75"},
+		"with church tax":    {"0.08", "1000", "278.19"},
+		"loss":               {"0", "-1000", "0"},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			g := &GermanTax{ChurchTaxRate: decimal.RequireFromString(testCase.ChurchTaxRate)}
+			tax := g.Rate(decimal.RequireFromString(testCase.Income))
+			if expected := decimal.RequireFromString(testCase.Expected); !tax.Equal(expected) {
+				t.Fatalf("expected %s, got %s", expected, tax)
+			}
+		})
+	}
+}
+
+func TestGermanTax(t *testing.T) {
+	var (
+		stock = &Stock{ISIN: "IE00B4L5Y983", FundType: EquityFund}
+		depot = &Depot{
+			Name: "comdirect",
+			Tax:  &GermanTax{Allowance: decimal.NewFromInt(1000)},
+		}
+	)
+	stock.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2020, 1, 1),
+			Amount: decimal.RequireFromString("-10000"),
+			Shares: decimal.RequireFromString("-100"),
+			Depot:  "comdirect",
+			Stock:  stock,
+		},
+		{
+			Type:   Sell,
+			Date:   Date(2020, 6, 1),
+			Amount: decimal.RequireFromString("6000"),
+			Shares: decimal.RequireFromString("50"),
+			Depot:  "comdirect",
+			Stock:  stock,
+		},
+		{
+			Type:   Sell,
+			Date:   Date(2020, 9, 1),
+			Amount: decimal.RequireFromString("4000"),
+			Shares: decimal.RequireFromString("50"),
+			Depot:  "comdirect",
+			Stock:  stock,
+		},
+		{
+			Type:   Dividend,
+			Date:   Date(2021, 3, 1),
+			Amount: decimal.RequireFromString("3000"),
+			Depot:  "comdirect",
+			Stock:  stock,
+		},
+	}
+
+	transactions, stats, err := CalculateStats([]*Stock{stock}, []*Depot{depot})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, stockStats, err := CalculateStockStats([]*Stock{stock}, []*Depot{depot})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		gain     = stats[stock.Transactions[1]]
+		loss     = stats[stock.Transactions[2]]
+		dividend = stats[stock.Transactions[3]]
+		account  = PortfolioAt(transactions, stats, Date(2020, 12, 31)).TaxAccounts["comdirect"]
+	)
+
+	testCases := map[string]struct {
+		Actual   decimal.Decimal
+		Expected string
+	}{
+		// 30% of the gain of 1000 is exempt, the rest is within the allowance.
+		"gain tax":       {gain.Sell.Tax, "0"},
+		"gain after tax": {gain.Sell.AfterTaxProfit, "1000"},
+		"allowance used": {account.AllowanceUsed, "700"},
+		"loss tax":       {loss.Sell.Tax, "0"},
+		"losses carried": {account.Losses, "700"},
+		// 2100 taxable, less 700 losses and the new allowance of 1000.
+		"dividend tax":       {dividend.Dividend.Tax, "105.5"},
+		"dividend after tax": {dividend.Dividend.AfterTaxAmount, "2894.5"},
+		"estimated taxes":    {dividend.Portfolio.EstimatedTaxes(), "105.5"},
+		"after tax income":   {dividend.Portfolio.AfterTaxIncome(), "2894.5"},
+		"stock dividend tax": {stockStats[stock.Transactions[3]].Dividend.Tax, "105.5"},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if expected := decimal.RequireFromString(testCase.Expected); !testCase.Actual.Equal(expected) {
+				t.Fatalf("expected %s, got %s", expected, testCase.Actual)
+			}
+		})
+	}
+}
+
+func TestGermanTaxDateOrder(t *testing.T) {
+	var (
+		late  = &Stock{ISIN: "US0378331005"}
+		early = &Stock{ISIN: "US88160R1014"}
+		depot = &Depot{
+			Name: "comdirect",
+			Tax:  &GermanTax{Allowance: decimal.NewFromInt(1000)},
+		}
+	)
+	for _, s := range []struct {
+		Stock *Stock
+		Sold  time.Time
+	}{
+		{late, Date(2020, 9, 1)},
+		{early, Date(2020, 3, 1)},
+	} {
+		s.Stock.Transactions = Transactions{
+			{
+				Type:   Buy,
+				Date:   Date(2020, 1, 2),
+				Amount: decimal.RequireFromString("-1000"),
+				Shares: decimal.RequireFromString("-10"),
+				Depot:  "comdirect",
+				Stock:  s.Stock,
+			},
+			{
+				Type:   Sell,
+				Date:   s.Sold,
+				Amount: decimal.RequireFromString("2000"),
+				Shares: decimal.RequireFromString("10"),
+				Depot:  "comdirect",
+				Stock:  s.Stock,
+			},
+		}
+	}
+
+	// The allowance is used by the earlier sell, although its stock comes
+	// second.
+	_, stats, err := CalculateStats([]*Stock{late, early}, []*Depot{depot})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tax := stats[early.Transactions[1]].Sell.Tax; !tax.IsZero() {
+		t.Fatalf("expected no tax on the earlier sell, got %s", tax)
+	}
+	if tax := stats[late.Transactions[1]].Sell.Tax; !tax.Equal(decimal.RequireFromString("263.75")) {
+		t.Fatalf("expected 263.75 tax on the later sell, got %s", tax)
+	}
+}
+
+func TestCalculateVorabpauschale(t *testing.T) {
+	var (
+		stock = &Stock{ISIN: "IE00B4L5Y983", FundType: EquityFund}
+		depot = &Depot{
+			Name: "comdirect",
+			Tax:  &GermanTax{},
+		}
+	)
+	stock.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2022, 6, 1),
+			Amount: decimal.RequireFromString("-5000"),
+			Shares: decimal.RequireFromString("-50"),
+			Depot:  "comdirect",
+			Stock:  stock,
+		},
+		{
+			Type:   Buy,
+			Date:   Date(2023, 3, 15),
+			Amount: decimal.RequireFromString("-10000"),
+			Shares: decimal.RequireFromString("-100"),
+			Depot:  "comdirect",
+			Stock:  stock,
+		},
+	}
+
+	transactions, stats, err := CalculateStats([]*Stock{stock}, []*Depot{depot})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	price := func(stock *Stock, date time.Time) decimal.Decimal {
+		if date.Year() < 2023 {
+			return decimal.NewFromInt(100)
+		}
+		return decimal.NewFromInt(110)
+	}
+
+	results := CalculateVorabpauschale(price, transactions, stats, 2023)
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	v := results[0]
+
+	testCases := map[string]struct {
+		Actual   decimal.Decimal
+		Expected string
+	}{
+		// 1.785 per share: 50 shares for the whole year, 100 shares for ten months.
+		"amount":  {v.Amount, "238"},
+		"taxable": {v.Taxable, "166.6"},
+		"tax":     {v.Tax, "43.94"},
+		"shares":  {v.Shares, "150"},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if expected := decimal.RequireFromString(testCase.Expected); !testCase.Actual.Equal(expected) {
+				t.Fatalf("expected %s, got %s", expected, testCase.Actual)
+			}
+		})
+	}
+}
@@ -1,17 +1,124 @@
 package cf
 
 import (
+	"sort"
 	"time"
 
 	"github.com/shopspring/decimal"
 )
 
+type TransactionType string
+
+const (
+	Buy      TransactionType = "buy"
+	Sell     TransactionType = "sell"
+	Dividend TransactionType = "dividend"
+	Coupon   TransactionType = "coupon"
+	Split    TransactionType = "split"
+	Transfer TransactionType = "transfer"
+	Merger   TransactionType = "merger"
+	SpinOff  TransactionType = "spinoff"
+
+	// Short sells shares not held, which are bought back by Cover.
+	Short TransactionType = "short"
+	Cover TransactionType = "cover"
+
+	// Exercise closes held contracts of an option or warrant and
+	// Assignment closes written contracts. Both close the contracts at
+	// zero; the trade of the underlying is a separate buy or sell.
+	Exercise   TransactionType = "exercise"
+	Assignment TransactionType = "assignment"
+
+	// Cash transactions of a depot
+	Deposit    TransactionType = "deposit"
+	Withdrawal TransactionType = "withdrawal"
+	Interest   TransactionType = "interest"
+	Fee        TransactionType = "fee"
+)
+
+// IsCash reports whether the transaction type is a cash transaction of a
+// depot rather than a transaction of a stock.
+func (tt TransactionType) IsCash() bool {
+	switch tt {
+	case Deposit, Withdrawal, Interest, Fee:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsIncome reports whether the transaction type is income from a stock,
+// which are dividends and coupons of bonds.
+func (tt TransactionType) IsIncome() bool {
+	return tt == Dividend || tt == Coupon
+}
+
+// IsExternal reports whether the transaction type moves money into or out of
+// a depot.
+func (tt TransactionType) IsExternal() bool {
+	return tt == Deposit || tt == Withdrawal
+}
+
+// InferTransactionType returns the type of a transaction without an explicit
+// type: buys have negative shares, sells positive shares and dividends none.
+func InferTransactionType(shares decimal.Decimal) TransactionType {
+	switch {
+	case shares.IsPositive():
+		return Sell
+	case shares.IsNegative():
+		return Buy
+	default:
+		return Dividend
+	}
+}
+
 type Transaction struct {
+	Type   TransactionType
 	Date   time.Time
 	Amount decimal.Decimal
 	Shares decimal.Decimal
+	Fees   decimal.Decimal
+	Taxes  decimal.Decimal
+	Ratio  Ratio
 	Depot  string
-	Stock  *Stock
+
+	// Lot names the batch created by a buy, which defaults to its date, or
+	// the batch closed by a sell in a depot with SpecificLot.
+	Lot string
+
+	// SavingsPlan marks a buy as the real fill of a savings plan execution,
+	// which it replaces.
+	SavingsPlan bool
+
+	// ReinvestedShares are the shares bought by reinvesting a dividend. A
+	// reinvested dividend is income, but no cash flow.
+	ReinvestedShares decimal.Decimal
+
+	// AccruedInterest is the part of Amount of a bond buy or sell that is
+	// interest accrued since the last coupon date. It is negative for buys
+	// and not part of the cost basis or proceeds, but income.
+	AccruedInterest decimal.Decimal
+
+	// ToDepot is the depot a transfer moves shares to from Depot.
+	ToDepot string
+
+	// Acquired is the original acquisition date of the shares of a
+	// transfer into a depot without source depot.
+	Acquired time.Time
+
+	// Source is the ID of the stock whose shares are exchanged by a
+	// merger or which spins off shares, or the ID of the option exercised or
+	// assigned by a buy or sell of its underlying. CostShare is the share of
+	// the cost basis of the source stock allocated by a spin-off.
+	Source    string
+	CostShare decimal.Decimal
+
+	// Stock is the stock of the transaction or nil for cash transactions.
+	Stock *Stock
+
+	// Currency is the currency of Amount, Fees and Taxes. If empty, the
+	// currency of the stock is used.
+	Currency string
 }
 
 func (t *Transaction) Clone() *Transaction {
@@ -20,6 +127,37 @@ func (t *Transaction) Clone() *Transaction {
 	return cloned
 }
 
+// GrossAmount returns the amount of the transaction before fees and taxes.
+// Amount is the net cash flow including fees and taxes.
+func (t *Transaction) GrossAmount() decimal.Decimal {
+	return t.Amount.Add(t.Fees).Add(t.Taxes)
+}
+
+// principal returns the transaction without the accrued interest.
+func (t *Transaction) principal() *Transaction {
+	if t.AccruedInterest.IsZero() {
+		return t
+	}
+	principal := t.Clone()
+	principal.Amount = t.Amount.Sub(t.AccruedInterest)
+	principal.AccruedInterest = decimal.Zero
+	return principal
+}
+
+// Reinvested returns whether t is a reinvested dividend.
+func (t *Transaction) Reinvested() bool {
+	return t.Type == Dividend && t.ReinvestedShares.IsPositive()
+}
+
+// CashFlow returns the amount paid or received, which is zero for reinvested
+// dividends.
+func (t *Transaction) CashFlow() decimal.Decimal {
+	if t.Reinvested() {
+		return decimal.Zero
+	}
+	return t.Amount
+}
+
 type Transactions []*Transaction
 
 func (ts Transactions) ForDepot(depot string) Transactions {
@@ -32,6 +170,18 @@ func (ts Transactions) ForDepot(depot string) Transactions {
 	return transactions
 }
 
+// Sort sorts the transactions by date. Splits take effect before any other
+// transaction on the same day.
+func (ts Transactions) Sort() {
+	sort.SliceStable(ts, func(i, j int) bool {
+		a, b := ts[i], ts[j]
+		if a.Date.Equal(b.Date) {
+			return a.Type == Split && b.Type != Split
+		}
+		return a.Date.Before(b.Date)
+	})
+}
+
 func (ts Transactions) Clone() Transactions {
 	cloned := make(Transactions, len(ts))
 	for i, t := range ts {
@@ -44,39 +194,20 @@ func (ts Transactions) Stats() (map[*Transaction]Stats, error) {
 	stats := make(map[*Transaction]Stats)
 
 	for i, t := range ts {
-		s := Stats{
-			Transaction: t,
-		}
-
+		var portfolio Portfolio
 		if i == 0 {
-			s.Portfolio = Portfolio{}
+			portfolio = NewPortfolio(nil)
 		} else {
-			s.Portfolio = stats[ts[i-1]].Portfolio.Clone()
+			portfolio = stats[ts[i-1]].Portfolio.Clone()
 		}
 
-		if t.Shares.IsPositive() {
-			// Sell
-			ret, profit, err := s.Portfolio.RemoveShares(t.Stock, t)
-			if err != nil {
-				return nil, err
-			}
-			s.Sell.Return, s.Sell.Profit = ret, profit
-			s.Sell.PricePerShare = t.Amount.Div(t.Shares)
-		} else if t.Shares.IsNegative() {
-			// Buy
-			s.Portfolio.AddShares(t.Stock, t)
-			s.Buy.PricePerShare = t.Amount.Div(t.Shares)
-		} else {
-			// Dividend
-			ret, err := s.Portfolio.AddDividend(t.Stock, t)
-			if err != nil {
-				return nil, err
-			}
-			s.Dividend.Return = ret
+		s, err := portfolio.apply(t.Stock, t)
+		if err != nil {
+			return nil, err
 		}
-
 		stats[t] = s
 	}
 
+	adjustForSplits(ts, stats)
 	return stats, nil
 }
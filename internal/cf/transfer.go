@@ -0,0 +1,150 @@
+package cf
+
+import (
+	"errors"
+
+	"github.com/shopspring/decimal"
+)
+
+// TransferStats are the stats of a transfer. Lots are the moved parts of the
+// batches as they were before the transfer.
+type TransferStats struct {
+	Lots []PortfolioStockBatch
+}
+
+// Transfer moves the shares transferred by t of stock s.
+func (p Portfolio) Transfer(s *Stock, t *Transaction) (TransferStats, error) {
+	ps, ok := p.Stocks[s]
+	if !ok {
+		if t.Depot != "" {
+			return TransferStats{}, errors.New("cf: stock not in portfolio")
+		}
+		ps = &PortfolioStock{}
+		p.Stocks[s] = ps
+	}
+	lots, err := ps.Transfer(t, p.LotMethod(s, t.Depot))
+	if err != nil {
+		return TransferStats{}, err
+	}
+	return TransferStats{Lots: lots}, nil
+}
+
+// Transfer moves the shares transferred by t from the batches in t.Depot, in
+// the order given by method, to t.ToDepot, keeping their dates and cost
+// basis. Batches in a depot with average cost are averaged first. Without
+// t.ToDepot, the shares leave the portfolio. Without t.Depot, t adds a lot
+// acquired on t.Acquired.
+func (ps *PortfolioStock) Transfer(t *Transaction, method LotMethod) ([]PortfolioStockBatch, error) {
+	if t.Depot == "" {
+		b := PortfolioStockBatch{
+			Lot:           t.Lot,
+			Depot:         t.ToDepot,
+			Date:          t.Acquired,
+			Shares:        t.Shares.Abs(),
+			PricePerShare: t.Amount.Div(t.Shares),
+			Fees:          t.Fees,
+			Taxes:         t.Taxes,
+			Transactions:  Transactions{t},
+		}
+		if b.Lot == "" {
+			b.Lot = ps.newLot(b.Date)
+		}
+		ps.Batches = append(ps.Batches, b)
+		return []PortfolioStockBatch{b}, nil
+	}
+
+	// A transfer naming a lot moves that lot whatever the lot method, as
+	// it realizes no gain.
+	if t.Lot != "" {
+		method = SpecificLot
+	}
+	if method == AverageCost {
+		ps.average(t.Depot)
+	}
+
+	lots := ps.lots(t, method)
+	available := decimal.Zero
+	for _, i := range lots {
+		available = available.Add(ps.Batches[i].Shares)
+	}
+	if available.LessThan(t.Shares) {
+		return nil, errors.New("cf: invalid transaction: no batches left")
+	}
+
+	var (
+		toMove  = t.Shares
+		moved   []PortfolioStockBatch
+		added   []PortfolioStockBatch
+		removed = map[int]bool{}
+	)
+	for _, i := range lots {
+		if !toMove.IsPositive() {
+			break
+		}
+
+		var (
+			b    = ps.Batches[i]
+			part = b
+			full = !toMove.LessThan(b.Shares)
+		)
+		if !full {
+			part = b.part(toMove)
+			ps.Batches[i].Shares = b.Shares.Sub(part.Shares)
+			ps.Batches[i].Fees = b.Fees.Sub(part.Fees)
+			ps.Batches[i].Taxes = b.Taxes.Sub(part.Taxes)
+			ps.Batches[i].Transactions = append(b.Transactions, b.transferOut(t, part))
+		}
+		toMove = toMove.Sub(part.Shares)
+		moved = append(moved, part)
+
+		if t.ToDepot == "" {
+			removed[i] = full
+			continue
+		}
+
+		part.Transactions = append(b.Transactions[:0:0], b.Transactions...)
+		if !full {
+			part.Transactions = append(part.Transactions, b.transferOut(t, ps.Batches[i]))
+		}
+		part.Transactions = append(part.Transactions, &Transaction{
+			Type:    Transfer,
+			Date:    t.Date,
+			Shares:  part.Shares,
+			Depot:   t.Depot,
+			ToDepot: t.ToDepot,
+			Lot:     b.Lot,
+			Stock:   t.Stock,
+		})
+		part.Depot = t.ToDepot
+		if full {
+			ps.Batches[i] = part
+		} else {
+			added = append(added, part)
+		}
+	}
+
+	batches := ps.Batches[:0:0]
+	for i, b := range ps.Batches {
+		if !removed[i] {
+			batches = append(batches, b)
+		}
+	}
+	ps.Batches = append(batches, added...)
+
+	return moved, nil
+}
+
+// transferOut returns a transfer of the shares of part out of batch b at
+// their cost basis, which records the split of a batch by transfer t in the
+// transactions of the batch.
+func (b PortfolioStockBatch) transferOut(t *Transaction, part PortfolioStockBatch) *Transaction {
+	return &Transaction{
+		Type:   Transfer,
+		Date:   t.Date,
+		Amount: part.Invested(),
+		Shares: part.Shares,
+		Depot:  b.Depot,
+		Lot:    b.Lot,
+		Stock:  t.Stock,
+	}
+}
@@ -0,0 +1,194 @@
+package cf
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestTransfer(t *testing.T) {
+	stock := &Stock{ISIN: "US0378331005"}
+	stock.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2019, 1, 1),
+			Amount: decimal.RequireFromString("-1000"),
+			Shares: decimal.RequireFromString("-10"),
+			Depot:  "A",
+			Stock:  stock,
+		},
+		{
+			Type:   Buy,
+			Date:   Date(2020, 1, 1),
+			Amount: decimal.RequireFromString("-1500"),
+			Shares: decimal.RequireFromString("-10"),
+			Depot:  "A",
+			Stock:  stock,
+		},
+		{
+			Type:    Transfer,
+			Date:    Date(2020, 6, 1),
+			Shares:  decimal.RequireFromString("15"),
+			Depot:   "A",
+			ToDepot: "B",
+			Stock:   stock,
+		},
+		{
+			Type:   Sell,
+			Date:   Date(2020, 9, 1),
+			Amount: decimal.RequireFromString("1000"),
+			Shares: decimal.RequireFromString("5"),
+			Depot:  "B",
+			Stock:  stock,
+		},
+	}
+
+	transactions, stats, err := CalculateStats([]*Stock{stock}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		transfer = stats[stock.Transactions[2]]
+		sell     = stats[stock.Transactions[3]]
+		ps       = sell.Portfolio.Stocks[stock]
+		depotA   = depotStock(t, stock, stats, "A")
+		depotB   = depotStock(t, stock, stats, "B")
+	)
+
+	shares := func(ps *PortfolioStock, depot string) decimal.Decimal {
+		shares := decimal.Zero
+		for _, b := range ps.Batches {
+			if b.Depot == depot {
+				shares = shares.Add(b.Shares)
+			}
+		}
+		return shares
+	}
+
+	testCases := map[string]struct {
+		Actual   decimal.Decimal
+		Expected string
+	}{
+		"transferred shares":      {shares(transfer.Portfolio.Stocks[stock], "B"), "15"},
+		"remaining shares":        {shares(transfer.Portfolio.Stocks[stock], "A"), "5"},
+		"invested after transfer": {transfer.Portfolio.Stocks[stock].Invested(), "2500"},
+		"realized by transfer":    {transfer.Portfolio.Stocks[stock].RealizedProfit, "0"},
+		"sell profit":             {sell.Sell.Profit, "500"},
+		"shares after sell":       {shares(ps, "B"), "10"},
+		"depot A shares":          {depotA.Shares(), "5"},
+		"depot A invested":        {depotA.Invested(), "750"},
+		"depot B shares":          {depotB.Shares(), "10"},
+		"depot B realized profit": {depotB.RealizedProfit, "500"},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if expected := decimal.RequireFromString(testCase.Expected); !testCase.Actual.Equal(expected) {
+				t.Fatalf("expected %s, got %s", expected, testCase.Actual)
+			}
+		})
+	}
+
+	if lot := sell.Sell.Lots[0]; !lot.Acquired.Equal(Date(2019, 1, 1)) || !lot.LongTerm() {
+		t.Fatalf("expected long-term lot acquired on 2019-01-01, got %s", lot.Acquired)
+	}
+
+	for _, b := range ps.Batches {
+		if _, err := b.Transactions.Stats(); err != nil {
+			t.Fatalf("batch %s in %s: %s", b.Lot, b.Depot, err)
+		}
+	}
+
+	// The IRR is the same as without the transfer.
+	withoutTransfer := &Stock{ISIN: stock.ISIN}
+	for _, tx := range stock.Transactions {
+		if tx.Type != Transfer {
+			tx = tx.Clone()
+			tx.Depot = "A"
+			tx.Stock = withoutTransfer
+			withoutTransfer.Transactions = append(withoutTransfer.Transactions, tx)
+		}
+	}
+	expectedTransactions, expectedStats, err := CalculateStats([]*Stock{withoutTransfer}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	price := func(s *Stock, date time.Time) decimal.Decimal {
+		return decimal.NewFromInt(200)
+	}
+	var (
+		begin    = Date(2019, 1, 1)
+		end      = Date(2020, 12, 31)
+		irr      = CalculateIRR(context.Background(), price, transactions, stats, begin, end)
+		expected = CalculateIRR(context.Background(), price, expectedTransactions, expectedStats, begin, end)
+	)
+	if math.Abs(irr-expected) > 1e-9 {
+		t.Fatalf("expected IRR %f, got %f", expected, irr)
+	}
+}
+
+// depotStock returns the portfolio stock of stock restricted to depot after
+// its last transaction.
+func depotStock(t *testing.T, stock *Stock, stats map[*Transaction]Stats, depot string) *PortfolioStock {
+	t.Helper()
+	filtered := stock.ForDepot(depot, stats)
+	transactions, stats, err := CalculateStats([]*Stock{filtered}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stats[transactions[len(transactions)-1]].Portfolio.Stocks[filtered]
+}
+
+func TestTransferLotMethod(t *testing.T) {
+	stock := &Stock{ISIN: "US0378331005"}
+	stock.Transactions = Transactions{
+		{
+			Type:   Buy,
+			Date:   Date(2019, 1, 1),
+			Amount: decimal.RequireFromString("-1000"),
+			Shares: decimal.RequireFromString("-10"),
+			Depot:  "A",
+			Stock:  stock,
+		},
+		{
+			Type:   Buy,
+			Date:   Date(2020, 1, 1),
+			Amount: decimal.RequireFromString("-1500"),
+			Shares: decimal.RequireFromString("-10"),
+			Depot:  "A",
+			Stock:  stock,
+		},
+		{
+			Type:    Transfer,
+			Date:    Date(2020, 6, 1),
+			Shares:  decimal.RequireFromString("10"),
+			Depot:   "A",
+			ToDepot: "B",
+			Stock:   stock,
+		},
+	}
+	depots := []*Depot{{Name: "A", LotMethod: LIFO}, {Name: "B"}}
+
+	_, stats, err := CalculateStockStats([]*Stock{stock}, depots)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		depotA = depotStock(t, stock, stats, "A")
+		depotB = depotStock(t, stock, stats, "B")
+	)
+	if expected := decimal.RequireFromString("1000"); !depotA.Invested().Equal(expected) {
+		t.Errorf("unexpected depot A invested: %s", depotA.Invested())
+	}
+	if expected := decimal.RequireFromString("1500"); !depotB.Invested().Equal(expected) {
+		t.Errorf("unexpected depot B invested: %s", depotB.Invested())
+	}
+	if date := depotB.Batches[0].Date; !date.Equal(Date(2020, 1, 1)) {
+		t.Errorf("unexpected depot B acquisition date: %s", date)
+	}
+}
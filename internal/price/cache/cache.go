@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -31,40 +32,62 @@ func (c *Cache) Price(stock *cf.Stock, date time.Time) decimal.Decimal {
 
 	date = cf.Date(date.Year(), int(date.Month()), date.Day())
 
-	stockPrices := c.prices[key(stock)]
-	if stockPrices == nil {
-		return decimal.Zero
-	}
-
-	n := len(stockPrices)
-	idx := sort.Search(n, func(i int) bool {
-		return !date.Before(stockPrices[i].Date)
-	})
-	if idx == n {
-		return decimal.Zero
-	}
-	return stockPrices[idx].Price
+	return lookup(c.prices[stock.ID()], date)
 }
 
-func (c *Cache) UpdateHistory(ctx context.Context, stocks []*cf.Stock) error {
-	prices := map[string][]cf.Price{}
+// Cached returns whether prices of stock are cached.
+func (c *Cache) Cached(stock *cf.Stock) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.prices[stock.ID()]) > 0
+}
 
+// UpdateHistory fetches the price histories of stocks since their first
+// transaction or savings plan execution, or since since if it is earlier. The
+// histories start a week earlier, so that there is a price for a first date
+// on a weekend or holiday. A stock whose prices cannot be fetched keeps its
+// cached prices without stopping the update of the others, and the errors of
+// all such stocks are returned.
+func (c *Cache) UpdateHistory(ctx context.Context, stocks []*cf.Stock, since time.Time) error {
+	var failed []string
 	for _, stock := range stocks {
-		stockPrices, err := c.provider.History(ctx, stock)
+		start := stock.Since()
+		if start.IsZero() || (!since.IsZero() && since.Before(start)) {
+			start = since
+		}
+		if !start.IsZero() {
+			start = start.AddDate(0, 0, -7)
+		}
+		stockPrices, err := c.provider.History(ctx, stock, start)
 		if err != nil {
-			return fmt.Errorf("fetching prices for %s: %v", stock.ISIN, err)
+			failed = append(failed, fmt.Sprintf("%s: %v", stock.ID(), err))
+			continue
 		}
 		sort.Slice(stockPrices, func(i, j int) bool {
 			return stockPrices[i].Date.After(stockPrices[j].Date)
 		})
-		prices[key(stock)] = stockPrices
-	}
 
-	c.mu.Lock()
-	c.prices = prices
-	c.mu.Unlock()
+		c.mu.Lock()
+		c.prices[stock.ID()] = stockPrices
+		c.mu.Unlock()
+	}
 
+	if len(failed) > 0 {
+		return fmt.Errorf("fetching prices for %s", strings.Join(failed, "; "))
+	}
 	return nil
 }
 
-func key(stock *cf.Stock) string { return stock.ISIN }
+// lookup returns the latest price on or before date. prices must be sorted
+// from newest to oldest.
+func lookup(prices []cf.Price, date time.Time) decimal.Decimal {
+	n := len(prices)
+	idx := sort.Search(n, func(i int) bool {
+		return !date.Before(prices[i].Date)
+	})
+	if idx == n {
+		return decimal.Zero
+	}
+	return prices[idx].Price
+}
@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -12,10 +13,18 @@ import (
 
 type provider struct {
 	prices map[string][]cf.Price
+	since  map[string]time.Time
 }
 
-func (p *provider) History(ctx context.Context, stock *cf.Stock) ([]cf.Price, error) {
-	return p.prices[stock.ISIN], nil
+func (p *provider) History(ctx context.Context, stock *cf.Stock, since time.Time) ([]cf.Price, error) {
+	if p.since != nil {
+		p.since[stock.ID()] = since
+	}
+	prices, ok := p.prices[stock.ISIN]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return prices, nil
 }
 
 func (p *provider) Current(ctx context.Context, stock *cf.Stock) (decimal.Decimal, error) {
@@ -57,7 +66,7 @@ func TestCache(t *testing.T) {
 	stock := &cf.Stock{ISIN: "US88160R1014"}
 
 	cache := New(provider)
-	if err := cache.UpdateHistory(context.Background(), []*cf.Stock{stock}); err != nil {
+	if err := cache.UpdateHistory(context.Background(), []*cf.Stock{stock}, time.Time{}); err != nil {
 		t.Fatal(err)
 	}
 
@@ -86,3 +95,147 @@ func TestCache(t *testing.T) {
 		})
 	}
 }
+
+func TestCacheUpdateError(t *testing.T) {
+	provider := &provider{
+		prices: map[string][]cf.Price{
+			"US88160R1014": []cf.Price{
+				{
+					Date:  cf.Date(2020, 11, 19),
+					Price: decimal.RequireFromString("499.269989"),
+				},
+			},
+		},
+	}
+
+	var (
+		unknown = &cf.Stock{ISIN: "XX0000000000"}
+		stock   = &cf.Stock{ISIN: "US88160R1014"}
+		cache   = New(provider)
+	)
+	if err := cache.UpdateHistory(context.Background(), []*cf.Stock{unknown, stock}, time.Time{}); err == nil {
+		t.Fatal("expected error")
+	}
+	if price := cache.Price(stock, cf.Date(2020, 11, 19)); !price.Equal(decimal.RequireFromString("499.269989")) {
+		t.Fatalf("unexpected price: %s", price)
+	}
+	if cache.Cached(unknown) || !cache.Cached(stock) {
+		t.Fatal("expected only the fetched stock to be cached")
+	}
+}
+
+func TestCacheUpdateSince(t *testing.T) {
+	provider := &provider{
+		prices: map[string][]cf.Price{},
+		since:  map[string]time.Time{},
+	}
+
+	var (
+		stock = &cf.Stock{ISIN: "US88160R1014", Transactions: cf.Transactions{{Type: cf.Buy, Date: cf.Date(2017, 3, 1)}}}
+		fresh = &cf.Stock{ISIN: "IE00B4L5Y983"}
+		cache = New(provider)
+	)
+	cache.UpdateHistory(context.Background(), []*cf.Stock{stock, fresh}, time.Time{})
+	if since := provider.since[stock.ID()]; !since.Equal(cf.Date(2017, 2, 22)) {
+		t.Fatalf("expected prices since a week before the first transaction, got %s", since)
+	}
+	if since := provider.since[fresh.ID()]; !since.IsZero() {
+		t.Fatalf("expected prices of the default period, got %s", since)
+	}
+
+	cache.UpdateHistory(context.Background(), []*cf.Stock{fresh}, cf.Date(2016, 1, 8))
+	if since := provider.since[fresh.ID()]; !since.Equal(cf.Date(2016, 1, 1)) {
+		t.Fatalf("expected prices since a week before the given date, got %s", since)
+	}
+}
+
+type rateProvider struct {
+	rates map[string][]cf.Price
+	since map[string]time.Time
+}
+
+func (p *rateProvider) History(ctx context.Context, from, to string, since time.Time) ([]cf.Price, error) {
+	p.since[from+to] = since
+	return p.rates[from+to], nil
+}
+
+func (p *rateProvider) Current(ctx context.Context, from, to string) (decimal.Decimal, error) {
+	return decimal.Zero, nil
+}
+
+func TestRates(t *testing.T) {
+	provider := &rateProvider{
+		rates: map[string][]cf.Price{
+			"USDEUR": []cf.Price{
+				{
+					Date:  cf.Date(2020, 11, 19),
+					Price: decimal.RequireFromString("0.8"),
+				},
+				{
+					Date:  cf.Date(2020, 11, 20),
+					Price: decimal.RequireFromString("0.5"),
+				},
+			},
+		},
+		since: map[string]time.Time{},
+	}
+
+	rates := NewRates(provider)
+	if err := rates.UpdateHistory(context.Background(), []string{"EUR", "USD"}, "EUR", map[string]time.Time{"USD": cf.Date(2020, 11, 19)}); err != nil {
+		t.Fatal(err)
+	}
+	if since := provider.since["USDEUR"]; !since.Equal(cf.Date(2020, 11, 12)) {
+		t.Fatalf("expected rates since a week before the first use, got %s", since)
+	}
+
+	testCases := map[string]struct {
+		From string
+		To   string
+		Date time.Time
+		Rate decimal.Decimal
+	}{
+		"same currency": {
+			From: "EUR",
+			To:   "EUR",
+			Date: cf.Date(2020, 11, 19),
+			Rate: decimal.New(1, 0),
+		},
+		"direct": {
+			From: "USD",
+			To:   "EUR",
+			Date: cf.Date(2020, 11, 19),
+			Rate: decimal.RequireFromString("0.8"),
+		},
+		"inverse": {
+			From: "EUR",
+			To:   "USD",
+			Date: cf.Date(2020, 11, 21),
+			Rate: decimal.RequireFromString("2"),
+		},
+		"before history": {
+			From: "USD",
+			To:   "EUR",
+			Date: cf.Date(2017, 3, 1),
+			Rate: decimal.Zero,
+		},
+		"inverse before history": {
+			From: "EUR",
+			To:   "USD",
+			Date: cf.Date(2017, 3, 1),
+			Rate: decimal.Zero,
+		},
+		"unknown": {
+			From: "CHF",
+			To:   "EUR",
+			Date: cf.Date(2020, 11, 19),
+			Rate: decimal.Zero,
+		},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if rate := rates.Rate(testCase.From, testCase.To, testCase.Date); !rate.Equal(testCase.Rate) {
+				t.Fatalf("unexpected rate: %s", rate)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/thcyron/cashflow/internal/cf"
+)
+
+// Rates caches the exchange rate histories of currency pairs.
+type Rates struct {
+	provider cf.RateProvider
+	mu       sync.RWMutex
+	rates    map[string][]cf.Price
+}
+
+func NewRates(provider cf.RateProvider) *Rates {
+	return &Rates{
+		provider: provider,
+		rates:    map[string][]cf.Price{},
+	}
+}
+
+// Rate returns the exchange rate from currency from into currency to on the
+// given date. If only the inverse pair is cached, its reciprocal is returned.
+// Unknown pairs and dates before the cached history get zero.
+func (r *Rates) Rate(from, to string, date time.Time) decimal.Decimal {
+	if from == to {
+		return decimal.New(1, 0)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	date = cf.Date(date.Year(), int(date.Month()), date.Day())
+
+	if rates, ok := r.rates[pairKey(from, to)]; ok {
+		return lookup(rates, date)
+	}
+	if inverse := lookup(r.rates[pairKey(to, from)], date); !inverse.IsZero() {
+		return decimal.New(1, 0).Div(inverse)
+	}
+	return decimal.Zero
+}
+
+// UpdateHistory fetches the exchange rate histories for converting each of
+// currencies into base since its date in since, which is the first use of the
+// currency, or of the default period of the provider without a date. The
+// history starts a week earlier, so that there is a rate for a first use on a
+// weekend or holiday.
+func (r *Rates) UpdateHistory(ctx context.Context, currencies []string, base string, since map[string]time.Time) error {
+	rates := map[string][]cf.Price{}
+
+	for _, currency := range currencies {
+		if currency == base {
+			continue
+		}
+		start := since[currency]
+		if !start.IsZero() {
+			start = start.AddDate(0, 0, -7)
+		}
+		pairRates, err := r.provider.History(ctx, currency, base, start)
+		if err != nil {
+			return fmt.Errorf("fetching rates for %s/%s: %v", currency, base, err)
+		}
+		sort.Slice(pairRates, func(i, j int) bool {
+			return pairRates[i].Date.After(pairRates[j].Date)
+		})
+		rates[pairKey(currency, base)] = pairRates
+	}
+
+	r.mu.Lock()
+	r.rates = rates
+	r.mu.Unlock()
+
+	return nil
+}
+
+func pairKey(from, to string) string { return from + "/" + to }
@@ -0,0 +1,71 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/thcyron/cashflow/internal/cf"
+)
+
+// Provider is a price provider which routes each stock to the provider
+// handling its kind, or to the fallback provider.
+type Provider struct {
+	fallback  cf.PriceProvider
+	providers map[cf.Kind]cf.PriceProvider
+}
+
+func NewProvider(fallback cf.PriceProvider) *Provider {
+	return &Provider{
+		fallback:  fallback,
+		providers: map[cf.Kind]cf.PriceProvider{},
+	}
+}
+
+// Handle routes stocks of kind to provider.
+func (p *Provider) Handle(kind cf.Kind, provider cf.PriceProvider) {
+	p.providers[kind] = provider
+}
+
+func (p *Provider) History(ctx context.Context, stock *cf.Stock, since time.Time) ([]cf.Price, error) {
+	return p.provider(stock).History(ctx, stock, since)
+}
+
+func (p *Provider) Current(ctx context.Context, stock *cf.Stock) (decimal.Decimal, error) {
+	return p.provider(stock).Current(ctx, stock)
+}
+
+func (p *Provider) provider(stock *cf.Stock) cf.PriceProvider {
+	if provider, ok := p.providers[stock.Kind]; ok {
+		return provider
+	}
+	return p.fallback
+}
+
+// PairProvider is a price provider which looks up the prices of stocks by
+// their pair symbol, like BTC-EUR for crypto currencies.
+type PairProvider struct {
+	pairs cf.PairProvider
+}
+
+func NewPairProvider(pairs cf.PairProvider) *PairProvider {
+	return &PairProvider{
+		pairs: pairs,
+	}
+}
+
+func (p *PairProvider) History(ctx context.Context, stock *cf.Stock, since time.Time) ([]cf.Price, error) {
+	if stock.Symbol == "" {
+		return nil, errors.New("mux: stock is missing symbol")
+	}
+	return p.pairs.History(ctx, stock.Pair(), since)
+}
+
+func (p *PairProvider) Current(ctx context.Context, stock *cf.Stock) (decimal.Decimal, error) {
+	if stock.Symbol == "" {
+		return decimal.Zero, errors.New("mux: stock is missing symbol")
+	}
+	return p.pairs.Current(ctx, stock.Pair())
+}
@@ -0,0 +1,69 @@
+package mux
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/thcyron/cashflow/internal/cf"
+)
+
+type provider struct {
+	price decimal.Decimal
+}
+
+func (p *provider) History(ctx context.Context, stock *cf.Stock, since time.Time) ([]cf.Price, error) {
+	return []cf.Price{{Date: cf.Date(2021, 1, 4), Price: p.price}}, nil
+}
+
+func (p *provider) Current(ctx context.Context, stock *cf.Stock) (decimal.Decimal, error) {
+	return p.price, nil
+}
+
+type pairs struct {
+	prices map[string]decimal.Decimal
+}
+
+func (p *pairs) History(ctx context.Context, pair string, since time.Time) ([]cf.Price, error) {
+	return []cf.Price{{Date: cf.Date(2021, 1, 4), Price: p.prices[pair]}}, nil
+}
+
+func (p *pairs) Current(ctx context.Context, pair string) (decimal.Decimal, error) {
+	return p.prices[pair], nil
+}
+
+func TestProvider(t *testing.T) {
+	p := NewProvider(&provider{price: decimal.RequireFromString("100")})
+	p.Handle(cf.CryptoKind, NewPairProvider(&pairs{
+		prices: map[string]decimal.Decimal{
+			"BTC-EUR": decimal.RequireFromString("27123.45"),
+		},
+	}))
+
+	testCases := map[string]struct {
+		Stock    *cf.Stock
+		Expected string
+	}{
+		"stock":  {Stock: &cf.Stock{ISIN: "US88160R1014", Symbol: "TSLA"}, Expected: "100"},
+		"crypto": {Stock: &cf.Stock{Symbol: "BTC", Currency: "EUR", Kind: cf.CryptoKind}, Expected: "27123.45"},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			current, err := p.Current(ctx, testCase.Stock)
+			if err != nil {
+				t.Fatal(err)
+			}
+			history, err := p.History(ctx, testCase.Stock, time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := decimal.RequireFromString(testCase.Expected)
+			if !current.Equal(expected) || !history[0].Price.Equal(expected) {
+				t.Fatalf("expected %s, got %s and %s", expected, current, history[0].Price)
+			}
+		})
+	}
+}
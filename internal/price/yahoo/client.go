@@ -17,9 +17,19 @@ func NewClient() *Client {
 	return &Client{}
 }
 
+// Daily returns the daily closing prices of symbol of the last two years.
 func (c *Client) Daily(ctx context.Context, symbol string) (map[time.Time]decimal.Decimal, error) {
+	return c.DailySince(ctx, symbol, time.Time{})
+}
+
+// DailySince returns the daily closing prices of symbol since the given date,
+// or of the last two years if since is zero or later.
+func (c *Client) DailySince(ctx context.Context, symbol string, since time.Time) (map[time.Time]decimal.Decimal, error) {
 	period2 := time.Now()
 	period1 := period2.AddDate(-2, 0, 0)
+	if !since.IsZero() && since.Before(period1) {
+		period1 = since
+	}
 
 	url := fmt.Sprintf(
 		"https://query1.finance.yahoo.com/v7/finance/download/%s?period1=%d&period2=%d&interval=1d&events=history",
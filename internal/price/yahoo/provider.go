@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"sort"
+	"time"
 
 	"github.com/shopspring/decimal"
 
@@ -20,11 +21,60 @@ func NewProvider(client *Client) *Provider {
 	}
 }
 
-func (p *Provider) History(ctx context.Context, stock *cf.Stock) ([]cf.Price, error) {
+func (p *Provider) History(ctx context.Context, stock *cf.Stock, since time.Time) ([]cf.Price, error) {
 	if stock.Symbol == "" {
 		return nil, errors.New("yahoo: stock is missing symbol")
 	}
-	ts, err := p.client.Daily(ctx, stock.Symbol)
+	return p.client.history(ctx, stock.Symbol, since)
+}
+
+func (p *Provider) Current(ctx context.Context, stock *cf.Stock) (decimal.Decimal, error) {
+	return p.client.Last(ctx, stock.Symbol)
+}
+
+// PairProvider provides prices of crypto currencies using Yahoo's pair
+// symbols like BTC-EUR.
+type PairProvider struct {
+	client *Client
+}
+
+func NewPairProvider(client *Client) *PairProvider {
+	return &PairProvider{
+		client: client,
+	}
+}
+
+func (p *PairProvider) History(ctx context.Context, pair string, since time.Time) ([]cf.Price, error) {
+	return p.client.history(ctx, pair, since)
+}
+
+func (p *PairProvider) Current(ctx context.Context, pair string) (decimal.Decimal, error) {
+	return p.client.Last(ctx, pair)
+}
+
+// RateProvider provides exchange rates using Yahoo's currency pair symbols.
+type RateProvider struct {
+	client *Client
+}
+
+func NewRateProvider(client *Client) *RateProvider {
+	return &RateProvider{
+		client: client,
+	}
+}
+
+func (p *RateProvider) History(ctx context.Context, from, to string, since time.Time) ([]cf.Price, error) {
+	return p.client.history(ctx, pairSymbol(from, to), since)
+}
+
+func (p *RateProvider) Current(ctx context.Context, from, to string) (decimal.Decimal, error) {
+	return p.client.Last(ctx, pairSymbol(from, to))
+}
+
+// history returns the daily prices of symbol since the given date, like
+// DailySince, sorted by date.
+func (c *Client) history(ctx context.Context, symbol string, since time.Time) ([]cf.Price, error) {
+	ts, err := c.DailySince(ctx, symbol, since)
 	if err != nil {
 		return nil, err
 	}
@@ -41,6 +91,6 @@ func (p *Provider) History(ctx context.Context, stock *cf.Stock) ([]cf.Price, er
 	return ps, nil
 }
 
-func (p *Provider) Current(ctx context.Context, stock *cf.Stock) (decimal.Decimal, error) {
-	return p.client.Last(ctx, stock.Symbol)
+func pairSymbol(from, to string) string {
+	return from + to + "=X"
 }
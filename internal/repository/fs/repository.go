@@ -20,33 +20,59 @@ func NewRepository(dir string) *Repository {
 }
 
 func (r *Repository) Stocks(ctx context.Context) ([]*cf.Stock, error) {
-	return readDir(r.dir)
+	stocks, _, _, err := readDir(r.dir)
+	return stocks, err
 }
 
-func readDir(path string) ([]*cf.Stock, error) {
-	var stocks []*cf.Stock
+func (r *Repository) Depots(ctx context.Context) ([]*cf.Depot, error) {
+	_, depots, _, err := readDir(r.dir)
+	return depots, err
+}
+
+func (r *Repository) Targets(ctx context.Context) (cf.Targets, error) {
+	_, _, targets, err := readDir(r.dir)
+	return targets, err
+}
+
+func readDir(path string) ([]*cf.Stock, []*cf.Depot, cf.Targets, error) {
+	var (
+		stocks  []*cf.Stock
+		depots  []*cf.Depot
+		targets cf.Targets
+	)
 	err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
 		if info.Mode().IsRegular() && strings.HasSuffix(path, ".toml") {
-			stock, err := readFile(path)
+			file, err := readFile(path)
 			if err != nil {
 				return fmt.Errorf("%s: %w", path, err)
 			}
-			stocks = append(stocks, stock)
+			if file.Stock != nil {
+				stocks = append(stocks, file.Stock)
+			}
+			if file.Depot != nil {
+				depots = append(depots, file.Depot)
+			}
+			if file.Targets != nil {
+				if targets != nil {
+					return fmt.Errorf("%s: duplicate targets", path)
+				}
+				targets = file.Targets
+			}
 			return err
 		}
 		return nil
 	})
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
-	return stocks, nil
+	return stocks, depots, targets, nil
 }
 
-func readFile(path string) (*cf.Stock, error) {
+func readFile(path string) (*toml.File, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
-	return toml.ReadStock(f)
+	return toml.Read(f)
 }
@@ -11,6 +11,7 @@ import (
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/shopspring/decimal"
 
 	gossh "golang.org/x/crypto/ssh"
 
@@ -29,6 +30,8 @@ type Repository struct {
 	mu         sync.RWMutex
 	validUntil time.Time
 	stocks     []*cf.Stock
+	depots     []*cf.Depot
+	targets    cf.Targets
 }
 
 func NewRepository(url string) *Repository {
@@ -52,57 +55,87 @@ func NewRepositoryWithSSH(url string, user string, pemBytes []byte) (*Repository
 }
 
 func (r *Repository) Stocks(ctx context.Context) ([]*cf.Stock, error) {
-	var stocks []*cf.Stock
+	stocks, _, _, err := r.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return cloneStocks(stocks), nil
+}
+
+func (r *Repository) Depots(ctx context.Context) ([]*cf.Depot, error) {
+	_, depots, _, err := r.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return cloneDepots(depots), nil
+}
+
+func (r *Repository) Targets(ctx context.Context) (cf.Targets, error) {
+	_, _, targets, err := r.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return cloneTargets(targets), nil
+}
+
+// get returns the cached stocks, depots and targets, fetching them if the
+// cache is expired. The returned values must not be modified.
+func (r *Repository) get(ctx context.Context) ([]*cf.Stock, []*cf.Depot, cf.Targets, error) {
 	r.mu.RLock()
 	if r.stocks != nil && r.validUntil.After(time.Now()) {
-		stocks = cloneStocks(r.stocks)
+		stocks, depots, targets := r.stocks, r.depots, r.targets
+		r.mu.RUnlock()
+		return stocks, depots, targets, nil
 	}
 	r.mu.RUnlock()
-	if stocks != nil {
-		return stocks, nil
-	}
 
-	stocks, err := r.getStocks(ctx)
+	stocks, depots, targets, err := r.fetch(ctx)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	r.mu.Lock()
 	r.stocks = stocks
+	r.depots = depots
+	r.targets = targets
 	r.validUntil = time.Now().Add(r.TTL)
 	r.mu.Unlock()
 
-	return cloneStocks(stocks), nil
+	return stocks, depots, targets, nil
 }
 
-func (r *Repository) getStocks(ctx context.Context) ([]*cf.Stock, error) {
+func (r *Repository) fetch(ctx context.Context) ([]*cf.Stock, []*cf.Depot, cf.Targets, error) {
 	options := &git.CloneOptions{
 		URL:  r.url,
 		Auth: r.publicKeys,
 	}
 	repo, err := git.Clone(memory.NewStorage(), nil, options)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	ref, err := repo.Head()
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	commit, err := repo.CommitObject(ref.Hash())
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	tree, err := commit.Tree()
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
-	var stocks []*cf.Stock
+	var (
+		stocks  []*cf.Stock
+		depots  []*cf.Depot
+		targets cf.Targets
+	)
 
-	tree.Files().ForEach(func(f *object.File) error {
+	err = tree.Files().ForEach(func(f *object.File) error {
 		if !strings.HasSuffix(f.Name, ".toml") {
 			return nil
 		}
@@ -111,15 +144,37 @@ func (r *Repository) getStocks(ctx context.Context) ([]*cf.Stock, error) {
 			return fmt.Errorf("reading %q: %w", f.Name, err)
 		}
 		defer rc.Close()
-		stock, nil := toml.ReadStock(rc)
+		file, err := toml.Read(rc)
 		if err != nil {
 			return fmt.Errorf("reading %q: %w", f.Name, err)
 		}
-		stocks = append(stocks, stock)
+		if file.Stock != nil {
+			stocks = append(stocks, file.Stock)
+		}
+		if file.Depot != nil {
+			depots = append(depots, file.Depot)
+		}
+		if file.Targets != nil {
+			if targets != nil {
+				return fmt.Errorf("reading %q: duplicate targets", f.Name)
+			}
+			targets = file.Targets
+		}
 		return nil
 	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
-	return stocks, nil
+	return stocks, depots, targets, nil
+}
+
+func cloneDepots(depots []*cf.Depot) []*cf.Depot {
+	cloned := make([]*cf.Depot, len(depots))
+	for i, depot := range depots {
+		cloned[i] = depot.Clone()
+	}
+	return cloned
 }
 
 func cloneStocks(stocks []*cf.Stock) []*cf.Stock {
@@ -129,3 +184,17 @@ func cloneStocks(stocks []*cf.Stock) []*cf.Stock {
 	}
 	return cloned
 }
+
+func cloneTargets(targets cf.Targets) cf.Targets {
+	if targets == nil {
+		return nil
+	}
+	cloned := cf.Targets{}
+	for d, weights := range targets {
+		cloned[d] = map[string]decimal.Decimal{}
+		for key, w := range weights {
+			cloned[d][key] = w
+		}
+	}
+	return cloned
+}
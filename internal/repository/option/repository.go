@@ -0,0 +1,34 @@
+package option
+
+import (
+	"context"
+	"time"
+
+	"github.com/thcyron/cashflow/internal/cf"
+)
+
+// Repository wraps a repository and expands the exercises, assignments and
+// expiries of the options and warrants among its stocks into transactions up
+// to today.
+type Repository struct {
+	cf.Repository
+}
+
+func NewRepository(repo cf.Repository) *Repository {
+	return &Repository{
+		Repository: repo,
+	}
+}
+
+func (r *Repository) Stocks(ctx context.Context) ([]*cf.Stock, error) {
+	stocks, err := r.Repository.Stocks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	today := cf.Date(now.Year(), int(now.Month()), now.Day())
+	if err := cf.ExpandOptions(stocks, today); err != nil {
+		return nil, err
+	}
+	return stocks, nil
+}
@@ -0,0 +1,48 @@
+package savingsplan
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/thcyron/cashflow/internal/cf"
+)
+
+// Repository wraps a repository and expands the savings plans of its stocks
+// into transactions up to today. Executions without a price are logged and
+// left out until their price is known.
+type Repository struct {
+	cf.Repository
+
+	logger    log.Logger
+	price     cf.PriceFunc
+	converter *cf.Converter
+}
+
+func NewRepository(logger log.Logger, repo cf.Repository, price cf.PriceFunc, converter *cf.Converter) *Repository {
+	return &Repository{
+		Repository: repo,
+		logger:     logger,
+		price:      price,
+		converter:  converter,
+	}
+}
+
+func (r *Repository) Stocks(ctx context.Context) ([]*cf.Stock, error) {
+	stocks, err := r.Repository.Stocks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	today := cf.Date(now.Year(), int(now.Month()), now.Day())
+	for _, stock := range stocks {
+		if len(stock.SavingsPlans) == 0 {
+			continue
+		}
+		for _, date := range stock.ExpandSavingsPlans(r.price, r.converter, today) {
+			r.logger.Log("msg", "skipping savings plan execution without price", "stock", stock.ID(), "date", date.Format("2006-01-02"))
+		}
+	}
+	return stocks, nil
+}
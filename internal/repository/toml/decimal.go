@@ -0,0 +1,74 @@
+package toml
+
+import (
+	"bytes"
+	"regexp"
+)
+
+var floatPattern = regexp.MustCompile(`^[+-]?[0-9][0-9_]*(\.[0-9][0-9_]*)?([eE][+-]?[0-9][0-9_]*)?$`)
+
+// exactDecimals quotes the float values of data, which TOML decodes as
+// float64, so they are decoded into decimal.Decimal without rounding, like
+// the 18 decimals of crypto currencies. Integers, strings, comments and dates
+// are left as they are.
+func exactDecimals(data []byte) []byte {
+	var (
+		out bytes.Buffer
+		i   = 0
+	)
+	for i < len(data) {
+		switch c := data[i]; {
+		case c == '#':
+			end := bytes.IndexByte(data[i:], '\n')
+			if end < 0 {
+				end = len(data) - i
+			}
+			out.Write(data[i : i+end])
+			i += end
+		case c == '"' || c == '\'':
+			end := stringEnd(data, i)
+			out.Write(data[i:end])
+			i = end
+		case c == '=':
+			out.WriteByte(c)
+			i++
+			for i < len(data) && (data[i] == ' ' || data[i] == '\t') {
+				out.WriteByte(data[i])
+				i++
+			}
+			end := i
+			for end < len(data) && bytes.IndexByte([]byte(" \t\r\n,}]#"), data[end]) < 0 {
+				end++
+			}
+			if token := data[i:end]; bytes.ContainsAny(token, ".eE") && floatPattern.Match(token) {
+				out.WriteByte('"')
+				out.Write(bytes.ReplaceAll(token, []byte("_"), nil))
+				out.WriteByte('"')
+				i = end
+			}
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.Bytes()
+}
+
+// stringEnd returns the index after the string starting at i, which is a
+// basic or literal string and may span multiple lines.
+func stringEnd(data []byte, i int) int {
+	quote := data[i : i+1]
+	if bytes.HasPrefix(data[i:], bytes.Repeat(quote, 3)) {
+		quote = bytes.Repeat(quote, 3)
+	}
+	for j := i + len(quote); j < len(data); j++ {
+		if data[j] == '\\' && quote[0] == '"' {
+			j++
+			continue
+		}
+		if bytes.HasPrefix(data[j:], quote) {
+			return j + len(quote)
+		}
+	}
+	return len(data)
+}
@@ -0,0 +1,99 @@
+package toml
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/pelletier/go-toml"
+	"github.com/shopspring/decimal"
+
+	"github.com/thcyron/cashflow/internal/cf"
+)
+
+type depotFile struct {
+	Depot struct {
+		Name      string
+		Currency  string
+		LotMethod string `toml:"lot_method"`
+
+		// Tax is the tax engine of the depot, "de" for German taxes.
+		Tax          string
+		TaxAllowance decimal.Decimal `toml:"tax_allowance"`
+		ChurchTax    decimal.Decimal `toml:"church_tax"`
+	}
+	Transactions []struct {
+		Date     toml.LocalDate
+		Type     string
+		Amount   decimal.Decimal
+		Currency string
+	} `toml:"transaction"`
+}
+
+func ReadDepot(r io.Reader) (*cf.Depot, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("ioutil.ReadAll: %w", err)
+	}
+	return readDepot(data)
+}
+
+func readDepot(data []byte) (*cf.Depot, error) {
+	var df depotFile
+	if err := toml.Unmarshal(exactDecimals(data), &df); err != nil {
+		return nil, fmt.Errorf("toml.Unmarshal: %w", err)
+	}
+
+	lotMethod, err := cf.ParseLotMethod(df.Depot.LotMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	depot := &cf.Depot{
+		Name:      df.Depot.Name,
+		Currency:  df.Depot.Currency,
+		LotMethod: lotMethod,
+	}
+	switch df.Depot.Tax {
+	case "":
+	case "de":
+		depot.Tax = &cf.GermanTax{
+			Allowance:     df.Depot.TaxAllowance,
+			ChurchTaxRate: df.Depot.ChurchTax,
+		}
+	default:
+		return nil, fmt.Errorf("invalid tax %q", df.Depot.Tax)
+	}
+	for _, t := range df.Transactions {
+		typ := cf.TransactionType(t.Type)
+		if !typ.IsCash() {
+			return nil, fmt.Errorf("invalid cash transaction type %q", t.Type)
+		}
+
+		// Deposits add to and withdrawals and fees subtract from the cash
+		// balance regardless of the sign in the file. Interest may be
+		// negative.
+		amount := t.Amount
+		switch typ {
+		case cf.Deposit:
+			amount = amount.Abs()
+		case cf.Withdrawal, cf.Fee:
+			amount = amount.Abs().Neg()
+		}
+
+		depot.Transactions = append(depot.Transactions, &cf.Transaction{
+			Type:     typ,
+			Date:     t.Date.In(time.UTC),
+			Amount:   amount,
+			Currency: t.Currency,
+			Depot:    depot.Name,
+		})
+	}
+	sort.SliceStable(depot.Transactions, func(i, j int) bool {
+		return depot.Transactions[i].Date.Before(depot.Transactions[j].Date)
+	})
+
+	return depot, nil
+}
@@ -14,16 +14,142 @@ import (
 
 type stockFile struct {
 	Stock struct {
-		Name   string
-		Symbol string
-		ISIN   string
+		Name      string
+		Symbol    string
+		ISIN      string
+		Currency  string
+		LotMethod string `toml:"lot_method"`
+		FundType  string `toml:"fund_type"`
+		Kind      string
+
+		// Nominal, Coupon, Frequency and Maturity describe bonds.
+		Nominal   decimal.Decimal
+		Coupon    decimal.Decimal
+		Frequency int
+		Maturity  *toml.LocalDate
+
+		// Underlying, OptionType, Strike, Expiry and Multiplier describe
+		// options and warrants.
+		Underlying string
+		OptionType string `toml:"option_type"`
+		Strike     decimal.Decimal
+		Expiry     *toml.LocalDate
+		Multiplier decimal.Decimal
+
+		AssetClass string `toml:"asset_class"`
+		Sector     string
+		Country    string
+		Tags       []string
+		Weights    map[string]map[string]decimal.Decimal
 	}
 	Transactions []struct {
+		// Type is "short" or "cover" for short sales and covers and
+		// inferred from the shares otherwise.
+		Type     string
+		Date     toml.LocalDate
+		Amount   decimal.Decimal
+		Shares   decimal.Decimal
+		Fees     decimal.Decimal
+		Taxes    decimal.Decimal
+		Currency string
+		Depot    string
+		Lot      string
+
+		// ReinvestedShares are the shares bought by reinvesting a
+		// dividend.
+		ReinvestedShares decimal.Decimal `toml:"reinvested_shares"`
+
+		// AccruedInterest is the accrued interest included in the
+		// amount of a bond buy or sell.
+		AccruedInterest decimal.Decimal `toml:"accrued_interest"`
+
+		// SavingsPlan marks a buy as the real fill of a savings plan
+		// execution.
+		SavingsPlan bool `toml:"savings_plan"`
+	} `toml:"transaction"`
+	Splits []struct {
+		Date  toml.LocalDate
+		Ratio string
+	} `toml:"split"`
+	Transfers []struct {
 		Date   toml.LocalDate
-		Amount decimal.Decimal
 		Shares decimal.Decimal
-		Depot  string
-	} `toml:"transaction"`
+		From   string
+		To     string
+		Lot    string
+	} `toml:"transfer"`
+
+	// CorporateActions are the mergers, spin-offs and ISIN changes by
+	// which the stock received shares of the source stock.
+	CorporateActions []struct {
+		Type      string
+		Date      toml.LocalDate
+		Source    string
+		Ratio     string
+		CostShare decimal.Decimal `toml:"cost_share"`
+	} `toml:"corporate_action"`
+
+	// Exercises are the exercises of held and assignments of written
+	// options and warrants.
+	Exercises []struct {
+		Date       toml.LocalDate
+		Contracts  decimal.Decimal
+		Depot      string
+		Assignment bool
+	} `toml:"exercise"`
+	SavingsPlans []struct {
+		Amount   decimal.Decimal
+		Fees     decimal.Decimal
+		Currency string
+		Interval string
+		Start    toml.LocalDate
+		End      *toml.LocalDate
+		Day      int
+		Depot    string
+	} `toml:"savings_plan"`
+}
+
+// File is a stock, depot or targets file.
+type File struct {
+	Stock   *cf.Stock
+	Depot   *cf.Depot
+	Targets cf.Targets
+}
+
+// Read reads a file which is either a depot file if it has a [depot] table, a
+// targets file if it has a [targets] table or a stock file otherwise.
+func Read(r io.Reader) (*File, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("ioutil.ReadAll: %w", err)
+	}
+
+	tree, err := toml.LoadBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("toml.LoadBytes: %w", err)
+	}
+
+	if tree.Has("depot") {
+		depot, err := readDepot(data)
+		if err != nil {
+			return nil, err
+		}
+		return &File{Depot: depot}, nil
+	}
+
+	if tree.Has("targets") {
+		targets, err := readTargets(data)
+		if err != nil {
+			return nil, err
+		}
+		return &File{Targets: targets}, nil
+	}
+
+	stock, err := readStock(data)
+	if err != nil {
+		return nil, err
+	}
+	return &File{Stock: stock}, nil
 }
 
 func ReadStock(r io.Reader) (*cf.Stock, error) {
@@ -31,25 +157,230 @@ func ReadStock(r io.Reader) (*cf.Stock, error) {
 	if err != nil {
 		return nil, fmt.Errorf("ioutil.ReadAll: %w", err)
 	}
+	return readStock(data)
+}
 
+func readStock(data []byte) (*cf.Stock, error) {
 	var sf stockFile
-	if err := toml.Unmarshal(data, &sf); err != nil {
+	if err := toml.Unmarshal(exactDecimals(data), &sf); err != nil {
 		return nil, fmt.Errorf("toml.Unmarshal: %w", err)
 	}
 
+	lotMethod, err := cf.ParseLotMethod(sf.Stock.LotMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	fundType, err := cf.ParseFundType(sf.Stock.FundType)
+	if err != nil {
+		return nil, err
+	}
+
+	kind, err := cf.ParseKind(sf.Stock.Kind)
+	if err != nil {
+		return nil, err
+	}
+	if f := sf.Stock.Frequency; f < 0 || (f > 0 && 12%f != 0) {
+		return nil, fmt.Errorf("invalid coupon frequency %d", f)
+	}
+	var optionType cf.OptionType
+	if kind == cf.OptionKind || kind == cf.WarrantKind {
+		if optionType, err = cf.ParseOptionType(sf.Stock.OptionType); err != nil {
+			return nil, err
+		}
+		if sf.Stock.Underlying == "" {
+			return nil, fmt.Errorf("missing %s underlying", kind)
+		}
+		if sf.Stock.Multiplier.IsNegative() {
+			return nil, fmt.Errorf("invalid multiplier %s", sf.Stock.Multiplier)
+		}
+	}
+
 	stock := &cf.Stock{
-		Name:   sf.Stock.Name,
-		Symbol: sf.Stock.Symbol,
-		ISIN:   sf.Stock.ISIN,
+		Name:      sf.Stock.Name,
+		Symbol:    sf.Stock.Symbol,
+		ISIN:      sf.Stock.ISIN,
+		Currency:  sf.Stock.Currency,
+		LotMethod: lotMethod,
+		FundType:  fundType,
+		Kind:      kind,
+
+		Nominal:   sf.Stock.Nominal,
+		Coupon:    sf.Stock.Coupon,
+		Frequency: sf.Stock.Frequency,
+
+		Underlying:   sf.Stock.Underlying,
+		OptionType:   optionType,
+		Strike:       sf.Stock.Strike,
+		ContractSize: sf.Stock.Multiplier,
+
+		AssetClass: sf.Stock.AssetClass,
+		Sector:     sf.Stock.Sector,
+		Country:    sf.Stock.Country,
+		Tags:       sf.Stock.Tags,
+	}
+	if sf.Stock.Maturity != nil {
+		stock.Maturity = sf.Stock.Maturity.In(time.UTC)
+	}
+	if sf.Stock.Expiry != nil {
+		stock.Expiry = sf.Stock.Expiry.In(time.UTC)
+	}
+	if len(sf.Stock.Weights) > 0 {
+		if stock.Weights, err = readWeights(sf.Stock.Weights); err != nil {
+			return nil, err
+		}
 	}
 	for _, t := range sf.Transactions {
+		typ := cf.InferTransactionType(t.Shares)
+		switch {
+		case t.Type == "":
+		case t.Type == string(cf.Short) && typ == cf.Sell, t.Type == string(cf.Cover) && typ == cf.Buy:
+			typ = cf.TransactionType(t.Type)
+		default:
+			return nil, fmt.Errorf("invalid transaction type %q with shares %s", t.Type, t.Shares)
+		}
+		if kind == cf.BondKind && typ == cf.Dividend {
+			typ = cf.Coupon
+		}
+		if !t.ReinvestedShares.IsZero() && (typ != cf.Dividend || t.ReinvestedShares.IsNegative()) {
+			return nil, fmt.Errorf("invalid reinvested shares %s", t.ReinvestedShares)
+		}
+		if !t.AccruedInterest.IsZero() && (kind != cf.BondKind || (typ != cf.Buy && typ != cf.Sell) || t.AccruedInterest.Sign() != t.Amount.Sign()) {
+			return nil, fmt.Errorf("invalid accrued interest %s", t.AccruedInterest)
+		}
+		if t.SavingsPlan && typ != cf.Buy {
+			return nil, fmt.Errorf("invalid savings plan %s", typ)
+		}
 		stock.Transactions = append(stock.Transactions, &cf.Transaction{
-			Date:   t.Date.In(time.UTC),
-			Amount: t.Amount,
-			Shares: t.Shares,
-			Depot:  t.Depot,
-			Stock:  stock,
+			Type:     typ,
+			Date:     t.Date.In(time.UTC),
+			Amount:   t.Amount,
+			Shares:   t.Shares,
+			Fees:     t.Fees,
+			Taxes:    t.Taxes,
+			Currency: t.Currency,
+			Depot:    t.Depot,
+			Lot:      t.Lot,
+			Stock:    stock,
+
+			ReinvestedShares: t.ReinvestedShares,
+			AccruedInterest:  t.AccruedInterest,
+			SavingsPlan:      t.SavingsPlan,
+		})
+	}
+	for _, s := range sf.Splits {
+		ratio, err := cf.ParseRatio(s.Ratio)
+		if err != nil {
+			return nil, err
+		}
+		stock.Transactions = append(stock.Transactions, &cf.Transaction{
+			Type:  cf.Split,
+			Date:  s.Date.In(time.UTC),
+			Ratio: ratio,
+			Stock: stock,
 		})
 	}
+
+	for _, t := range sf.Transfers {
+		if !t.Shares.IsPositive() {
+			return nil, fmt.Errorf("invalid transfer shares %s", t.Shares)
+		}
+		if t.From == "" || t.To == "" || t.From == t.To {
+			return nil, fmt.Errorf("invalid transfer from %q to %q", t.From, t.To)
+		}
+		stock.Transactions = append(stock.Transactions, &cf.Transaction{
+			Type:    cf.Transfer,
+			Date:    t.Date.In(time.UTC),
+			Shares:  t.Shares,
+			Depot:   t.From,
+			ToDepot: t.To,
+			Lot:     t.Lot,
+			Stock:   stock,
+		})
+	}
+	for _, a := range sf.CorporateActions {
+		t := &cf.Transaction{
+			Date:      a.Date.In(time.UTC),
+			Source:    a.Source,
+			CostShare: a.CostShare,
+			Stock:     stock,
+		}
+		switch a.Type {
+		case "merger", "isin_change":
+			t.Type = cf.Merger
+		case "spinoff":
+			t.Type = cf.SpinOff
+			if !a.CostShare.IsPositive() || !a.CostShare.LessThan(decimal.NewFromInt(1)) {
+				return nil, fmt.Errorf("invalid spin-off cost share %s", a.CostShare)
+			}
+		default:
+			return nil, fmt.Errorf("invalid corporate action type %q", a.Type)
+		}
+		if a.Source == "" || a.Source == stock.ID() {
+			return nil, fmt.Errorf("invalid corporate action source %q", a.Source)
+		}
+		ratio := "1:1"
+		if a.Ratio != "" {
+			ratio = a.Ratio
+		} else if a.Type != "isin_change" {
+			return nil, fmt.Errorf("missing %s ratio", a.Type)
+		}
+		if t.Ratio, err = cf.ParseRatio(ratio); err != nil {
+			return nil, err
+		}
+		stock.Transactions = append(stock.Transactions, t)
+	}
+	for _, e := range sf.Exercises {
+		if !stock.IsDerivative() {
+			return nil, fmt.Errorf("exercise of %s", kind)
+		}
+		if !e.Contracts.IsPositive() {
+			return nil, fmt.Errorf("invalid exercise contracts %s", e.Contracts)
+		}
+		t := &cf.Transaction{
+			Type:   cf.Exercise,
+			Date:   e.Date.In(time.UTC),
+			Shares: e.Contracts,
+			Depot:  e.Depot,
+			Stock:  stock,
+		}
+		if e.Assignment {
+			t.Type = cf.Assignment
+			t.Shares = e.Contracts.Neg()
+		}
+		stock.Transactions = append(stock.Transactions, t)
+	}
+	for _, p := range sf.SavingsPlans {
+		// Plans without an interval are monthly, see cf.SavingsPlan.
+		var interval cf.Interval
+		if p.Interval != "" {
+			var err error
+			if interval, err = cf.ParseInterval(p.Interval); err != nil {
+				return nil, err
+			}
+		}
+		if !p.Amount.IsPositive() {
+			return nil, fmt.Errorf("invalid savings plan amount %s", p.Amount)
+		}
+		if p.Day < 0 || p.Day > 31 {
+			return nil, fmt.Errorf("invalid savings plan day %d", p.Day)
+		}
+		plan := cf.SavingsPlan{
+			Amount:   p.Amount,
+			Fees:     p.Fees,
+			Currency: p.Currency,
+			Interval: interval,
+			Start:    p.Start.In(time.UTC),
+			Day:      p.Day,
+			Depot:    p.Depot,
+		}
+		if p.End != nil {
+			plan.End = p.End.In(time.UTC)
+		}
+		stock.SavingsPlans = append(stock.SavingsPlans, plan)
+	}
+
+	stock.Transactions.Sort()
+
 	return stock, nil
 }
@@ -22,42 +22,62 @@ func TestReadStock(t *testing.T) {
 	}
 
 	expectedStock := &cf.Stock{
-		Name:   "Tesla",
-		Symbol: "TSLA",
-		ISIN:   "US88160R1014",
+		Name:       "Tesla",
+		Symbol:     "TSLA",
+		ISIN:       "US88160R1014",
+		Currency:   "USD",
+		AssetClass: "equity",
+		Sector:     "Consumer Discretionary",
+		Country:    "US",
+		Tags:       []string{"ev", "growth"},
 	}
 	expectedStock.Transactions = []*cf.Transaction{
 		{
+			Type:   cf.Buy,
 			Date:   cf.Date(2017, 10, 6),
 			Amount: decimal.RequireFromString("-3925.90"),
 			Shares: decimal.RequireFromString("-25"),
 			Stock:  expectedStock,
 		},
 		{
+			Type:   cf.Sell,
 			Date:   cf.Date(2020, 1, 17),
 			Amount: decimal.RequireFromString("1531.50"),
 			Shares: decimal.RequireFromString("15"),
 			Stock:  expectedStock,
 		},
 		{
+			Type:  cf.Split,
+			Date:  cf.Date(2020, 8, 31),
+			Ratio: cf.Ratio{New: decimal.NewFromInt(5), Old: decimal.NewFromInt(1)},
+			Stock: expectedStock,
+		},
+		{
+			Type:   cf.Buy,
 			Date:   cf.Date(2020, 9, 23),
 			Amount: decimal.RequireFromString("-3800"),
 			Shares: decimal.RequireFromString("-10"),
 			Stock:  expectedStock,
 		},
 		{
+			Type:   cf.Sell,
 			Date:   cf.Date(2020, 10, 15),
 			Amount: decimal.RequireFromString("4500"),
 			Shares: decimal.RequireFromString("10"),
+			Fees:   decimal.RequireFromString("9.90"),
+			Taxes:  decimal.RequireFromString("180.37"),
 			Stock:  expectedStock,
 		},
 		{
+			Type:   cf.Buy,
 			Date:   cf.Date(2020, 12, 9),
 			Amount: decimal.RequireFromString("-7858.24"),
 			Shares: decimal.RequireFromString("-13"),
+			Fees:   decimal.RequireFromString("7.90"),
 			Stock:  expectedStock,
 		},
 		{
+			Type:   cf.Buy,
 			Date:   cf.Date(2020, 12, 15),
 			Amount: decimal.RequireFromString("-5066"),
 			Shares: decimal.RequireFromString("-8"),
@@ -69,3 +89,259 @@ func TestReadStock(t *testing.T) {
 		t.Fatal(cmp.Diff(expectedStock, stock))
 	}
 }
+
+func TestReadDepot(t *testing.T) {
+	f, err := os.Open("../../../testdata/comdirect.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := Read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Stock != nil {
+		t.Fatal("unexpected stock")
+	}
+
+	expectedDepot := &cf.Depot{
+		Name:      "comdirect",
+		Currency:  "EUR",
+		LotMethod: cf.FIFO,
+		Tax: &cf.GermanTax{
+			Allowance:     decimal.RequireFromString("801"),
+			ChurchTaxRate: decimal.RequireFromString("0.08"),
+		},
+		Transactions: []*cf.Transaction{
+			{
+				Type:   cf.Deposit,
+				Date:   cf.Date(2017, 10, 2),
+				Amount: decimal.RequireFromString("10000"),
+				Depot:  "comdirect",
+			},
+			{
+				Type:   cf.Interest,
+				Date:   cf.Date(2019, 12, 31),
+				Amount: decimal.RequireFromString("1.25"),
+				Depot:  "comdirect",
+			},
+			{
+				Type:   cf.Fee,
+				Date:   cf.Date(2020, 1, 31),
+				Amount: decimal.RequireFromString("-4.90"),
+				Depot:  "comdirect",
+			},
+			{
+				Type:   cf.Withdrawal,
+				Date:   cf.Date(2020, 6, 15),
+				Amount: decimal.RequireFromString("-2500"),
+				Depot:  "comdirect",
+			},
+		},
+	}
+
+	if !cmp.Equal(expectedDepot, file.Depot) {
+		t.Fatal(cmp.Diff(expectedDepot, file.Depot))
+	}
+}
+
+func TestReadTargets(t *testing.T) {
+	f, err := os.Open("../../../testdata/targets.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := Read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Stock != nil || file.Depot != nil {
+		t.Fatal("unexpected stock or depot")
+	}
+
+	expectedTargets := cf.Targets{
+		cf.AssetClass: {
+			"equity": decimal.RequireFromString("0.8"),
+			"bond":   decimal.RequireFromString("0.2"),
+		},
+		cf.Security: {
+			"IE00B4L5Y983": decimal.RequireFromString("0.6"),
+			"US88160R1014": decimal.RequireFromString("0.1"),
+		},
+	}
+
+	if !cmp.Equal(expectedTargets, file.Targets) {
+		t.Fatal(cmp.Diff(expectedTargets, file.Targets))
+	}
+}
+
+func TestReadSavingsPlan(t *testing.T) {
+	f, err := os.Open("../../../testdata/msci-world.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stock, err := ReadStock(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedPlans := []cf.SavingsPlan{
+		{
+			Amount:   decimal.RequireFromString("100"),
+			Fees:     decimal.RequireFromString("1.50"),
+			Currency: "EUR",
+			Interval: cf.Monthly,
+			Start:    cf.Date(2020, 1, 15),
+			End:      cf.Date(2020, 12, 31),
+			Day:      15,
+			Depot:    "comdirect",
+		},
+	}
+
+	if !cmp.Equal(expectedPlans, stock.SavingsPlans) {
+		t.Fatal(cmp.Diff(expectedPlans, stock.SavingsPlans))
+	}
+	if n := len(stock.Transactions); n != 1 {
+		t.Fatalf("expected 1 transaction, got %d", n)
+	}
+	if !stock.Transactions[0].SavingsPlan {
+		t.Fatal("expected the fill to be marked as savings plan")
+	}
+}
+
+func TestReadBond(t *testing.T) {
+	f, err := os.Open("../../../testdata/bund.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stock, err := ReadStock(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedStock := &cf.Stock{
+		Name:       "Bund 2025",
+		ISIN:       "DE0001102382",
+		Currency:   "EUR",
+		Kind:       cf.BondKind,
+		Nominal:    decimal.RequireFromString("1000"),
+		Coupon:     decimal.RequireFromString("0.04"),
+		Frequency:  2,
+		Maturity:   cf.Date(2025, 6, 15),
+		AssetClass: "bond",
+	}
+	expectedStock.Transactions = []*cf.Transaction{
+		{
+			Type:            cf.Buy,
+			Date:            cf.Date(2020, 3, 15),
+			Amount:          decimal.RequireFromString("-9899.45"),
+			Shares:          decimal.RequireFromString("-10"),
+			AccruedInterest: decimal.RequireFromString("-99.45"),
+			Depot:           "comdirect",
+			Stock:           expectedStock,
+		},
+		{
+			Type:   cf.Coupon,
+			Date:   cf.Date(2020, 6, 15),
+			Amount: decimal.RequireFromString("200"),
+			Depot:  "comdirect",
+			Stock:  expectedStock,
+		},
+	}
+
+	if !cmp.Equal(expectedStock, stock) {
+		t.Fatal(cmp.Diff(expectedStock, stock))
+	}
+}
+
+func TestReadCrypto(t *testing.T) {
+	f, err := os.Open("../../../testdata/bitcoin.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stock, err := ReadStock(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedStock := &cf.Stock{
+		Name:       "Bitcoin",
+		Symbol:     "BTC",
+		Currency:   "EUR",
+		Kind:       cf.CryptoKind,
+		AssetClass: "crypto",
+	}
+	expectedStock.Transactions = []*cf.Transaction{
+		{
+			Type:   cf.Buy,
+			Date:   cf.Date(2021, 1, 4),
+			Amount: decimal.RequireFromString("-1000"),
+			Shares: decimal.RequireFromString("-0.034567891234567891"),
+			Fees:   decimal.RequireFromString("1.49"),
+			Depot:  "wallet",
+			Stock:  expectedStock,
+		},
+		{
+			Type:   cf.Sell,
+			Date:   cf.Date(2021, 3, 1),
+			Amount: decimal.RequireFromString("500.25"),
+			Shares: decimal.RequireFromString("0.012"),
+			Depot:  "wallet",
+			Stock:  expectedStock,
+		},
+	}
+
+	if !cmp.Equal(expectedStock, stock) {
+		t.Fatal(cmp.Diff(expectedStock, stock))
+	}
+	if s := stock.Transactions[0].Shares.String(); s != "-0.034567891234567891" {
+		t.Fatalf("unexpected shares %s", s)
+	}
+}
+
+func TestReadOption(t *testing.T) {
+	f, err := os.Open("../../../testdata/apple-call.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stock, err := ReadStock(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedStock := &cf.Stock{
+		Name:         "Apple Call 150 01/2023",
+		Symbol:       "AAPL230120C00150000",
+		Currency:     "USD",
+		Kind:         cf.OptionKind,
+		Underlying:   "US0378331005",
+		OptionType:   cf.Call,
+		Strike:       decimal.RequireFromString("150"),
+		Expiry:       cf.Date(2023, 1, 20),
+		ContractSize: decimal.RequireFromString("100"),
+	}
+	expectedStock.Transactions = []*cf.Transaction{
+		{
+			Type:   cf.Short,
+			Date:   cf.Date(2022, 6, 1),
+			Amount: decimal.RequireFromString("450"),
+			Shares: decimal.RequireFromString("1"),
+			Fees:   decimal.RequireFromString("1.5"),
+			Stock:  expectedStock,
+		},
+		{
+			Type:   cf.Assignment,
+			Date:   cf.Date(2023, 1, 20),
+			Shares: decimal.RequireFromString("-1"),
+			Stock:  expectedStock,
+		},
+	}
+
+	if !cmp.Equal(expectedStock, stock) {
+		t.Fatal(cmp.Diff(expectedStock, stock))
+	}
+}
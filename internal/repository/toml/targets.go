@@ -0,0 +1,60 @@
+package toml
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/pelletier/go-toml"
+	"github.com/shopspring/decimal"
+
+	"github.com/thcyron/cashflow/internal/cf"
+)
+
+type targetsFile struct {
+	Targets map[string]map[string]decimal.Decimal
+}
+
+func ReadTargets(r io.Reader) (cf.Targets, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("ioutil.ReadAll: %w", err)
+	}
+	return readTargets(data)
+}
+
+func readTargets(data []byte) (cf.Targets, error) {
+	var tf targetsFile
+	if err := toml.Unmarshal(exactDecimals(data), &tf); err != nil {
+		return nil, fmt.Errorf("toml.Unmarshal: %w", err)
+	}
+	targets, err := readWeights(tf.Targets)
+	if err != nil {
+		return nil, err
+	}
+	return cf.Targets(targets), nil
+}
+
+// readWeights reads weights by dimension, which must not be negative and must
+// not add up to more than 1 for any dimension but Tag.
+func readWeights(weights map[string]map[string]decimal.Decimal) (map[cf.Dimension]map[string]decimal.Decimal, error) {
+	result := map[cf.Dimension]map[string]decimal.Decimal{}
+	for name, ws := range weights {
+		dimension, err := cf.ParseDimension(name)
+		if err != nil {
+			return nil, err
+		}
+		total := decimal.Zero
+		for _, w := range ws {
+			if w.IsNegative() {
+				return nil, fmt.Errorf("negative %s weight", name)
+			}
+			total = total.Add(w)
+		}
+		if dimension != cf.Tag && total.GreaterThan(decimal.NewFromInt(1)) {
+			return nil, fmt.Errorf("%s weights add up to more than 1", name)
+		}
+		result[dimension] = ws
+	}
+	return result, nil
+}